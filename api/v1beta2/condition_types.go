@@ -16,6 +16,12 @@ limitations under the License.
 
 package v1beta2
 
+const (
+	// VerifiedCondition indicates that the signature of the image selected by
+	// an ImagePolicy has been verified.
+	VerifiedCondition string = "Verified"
+)
+
 const (
 	// ImageURLInvalidReason represents the fact that a given repository has an invalid image URL.
 	ImageURLInvalidReason string = "ImageURLInvalid"
@@ -30,4 +36,20 @@ const (
 
 	// ReadOperationFailedReason signals a failure caused by a read operation.
 	ReadOperationFailedReason string = "ReadOperationFailed"
+
+	// RateLimitedReason signals that a scan was throttled because of the rate
+	// limit of the registry.
+	RateLimitedReason string = "RateLimited"
+
+	// TagMutatedReason signals that a tag was found to point at a different
+	// digest than in the previous scan.
+	TagMutatedReason string = "TagMutated"
+
+	// VerificationFailedReason signals that none of the candidate images has
+	// a valid signature.
+	VerificationFailedReason string = "VerificationFailed"
+
+	// NoEligibleTagsReason signals that none of the tags of an ImagePolicy
+	// satisfies its age constraints.
+	NoEligibleTagsReason string = "NoEligibleTags"
 )
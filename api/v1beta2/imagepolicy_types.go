@@ -40,6 +40,80 @@ type ImagePolicySpec struct {
 	// ordered and compared.
 	// +optional
 	FilterTags *TagFilter `json:"filterTags,omitempty"`
+	// Filters is an ordered list of filters applied to the tags after
+	// FilterTags. Each filter is applied to the values extracted by the
+	// previous one, and the values extracted by the last one are ordered by
+	// the policy.
+	// +kubebuilder:validation:MaxItems=10
+	// +optional
+	Filters []TagFilter `json:"filters,omitempty"`
+	// TieBreaker orders the tags the policy ranks equally, i.e. the tags from
+	// which the filters extract the same value.
+	// +optional
+	TieBreaker *TieBreaker `json:"tieBreaker,omitempty"`
+	// MinAge excludes the tags first seen by the ImageRepository more
+	// recently than the given duration, e.g. '2h', for new tags to soak
+	// before they are selected. The policy is reconciled again when a tag
+	// becomes old enough.
+	// +kubebuilder:validation:Type=string
+	// +kubebuilder:validation:Pattern="^([0-9]+(\\.[0-9]+)?(ms|s|m|h))+$"
+	// +optional
+	MinAge *metav1.Duration `json:"minAge,omitempty"`
+	// MaxAge excludes the tags first seen by the ImageRepository longer ago
+	// than the given duration, e.g. '720h' for 30 days.
+	// +kubebuilder:validation:Type=string
+	// +kubebuilder:validation:Pattern="^([0-9]+(\\.[0-9]+)?(ms|s|m|h))+$"
+	// +optional
+	MaxAge *metav1.Duration `json:"maxAge,omitempty"`
+	// Pin freezes the policy on the given tag, selected instead of the tag
+	// the policy would select, e.g. during an incident. The tag must have
+	// been scanned by the ImageRepository, and must not be denied.
+	// +kubebuilder:validation:Pattern="^[\\w][\\w.-]{0,127}$"
+	// +optional
+	Pin string `json:"pin,omitempty"`
+	// Deny lists the tags, or the digests in the form 'sha256:...', which
+	// must not be selected, e.g. known-bad releases. Denying digests requires
+	// the ImageRepository to reflect digests.
+	// +optional
+	Deny []string `json:"deny,omitempty"`
+	// Verify enables the verification of the signatures of the images. The
+	// policy selects the highest ranked tag with a valid signature.
+	// +optional
+	Verify *ImageVerification `json:"verify,omitempty"`
+}
+
+// ImageVerification specifies how to verify the signatures of the images.
+type ImageVerification struct {
+	// Provider specifies the technology used to sign the images.
+	// +kubebuilder:validation:Enum=cosign
+	// +kubebuilder:default:=cosign
+	// +optional
+	Provider string `json:"provider,omitempty"`
+	// SecretRef specifies the Kubernetes Secret containing the trusted public
+	// keys, in keys with a '.pub' suffix. When omitted, the signatures are
+	// verified keyless, with the certificates issued by Fulcio for the OIDC
+	// identities of the signers.
+	// +optional
+	SecretRef *meta.LocalObjectReference `json:"secretRef,omitempty"`
+	// MatchOIDCIdentity specifies the identities allowed to sign the images
+	// keyless. A signature is valid if its certificate matches any of them.
+	// It's required for keyless verification, i.e. when SecretRef is
+	// omitted.
+	// +optional
+	MatchOIDCIdentity []OIDCIdentityMatch `json:"matchOIDCIdentity,omitempty"`
+}
+
+// OIDCIdentityMatch specifies the OIDC issuer and subject of the identity
+// allowed to sign images keyless.
+type OIDCIdentityMatch struct {
+	// Issuer specifies a regular expression matching the OIDC issuer of the
+	// identity, e.g. 'https://token.actions.githubusercontent.com'.
+	// +required
+	Issuer string `json:"issuer"`
+	// Subject specifies a regular expression matching the subject of the
+	// identity, i.e. the email address or URI in the certificate.
+	// +required
+	Subject string `json:"subject"`
 }
 
 // ImagePolicyChoice is a union of all the types of policy that can be
@@ -55,6 +129,14 @@ type ImagePolicyChoice struct {
 	// Numerical set of rules to use for numerical ordering of the tags.
 	// +optional
 	Numerical *NumericalPolicy `json:"numerical,omitempty"`
+	// Newest set of rules to use for ordering the tags by the time they were
+	// created or first seen.
+	// +optional
+	Newest *NewestPolicy `json:"newest,omitempty"`
+	// CalVer gives the calendar versioning format of the tags to order, and
+	// the constraints of the selected version.
+	// +optional
+	CalVer *CalVerPolicy `json:"calver,omitempty"`
 }
 
 // SemVerPolicy specifies a semantic version policy.
@@ -63,6 +145,27 @@ type SemVerPolicy struct {
 	// version within the range that's a tag yields the latest image.
 	// +required
 	Range string `json:"range"`
+	// Prereleases lists the identifiers of the prereleases to consider, e.g.
+	// 'rc' or 'beta', matching prereleases like '1.0.0-rc.1' or
+	// '1.0.0-beta2'. When set, these prereleases are considered if their
+	// final release is within the range, whether the range includes
+	// prereleases or not, and the other prereleases are never considered.
+	// +optional
+	Prereleases []string `json:"prereleases,omitempty"`
+	// PreferPrerelease ranks the prereleases of a version above its final
+	// release, e.g. '1.0.0-beta.2' above '1.0.0', for the policy to keep
+	// tracking a prerelease channel after a final release.
+	// +optional
+	PreferPrerelease bool `json:"preferPrerelease,omitempty"`
+	// BuildMetadataOrder specifies the order of the tags differing only by
+	// their build metadata, e.g. '1.0.0+20240101' and '1.0.0+20240102'.
+	// Ascending order selects the highest build metadata, and descending
+	// order the lowest. The build metadata is compared like prereleases:
+	// identifier by identifier, numerically if both are numeric. When
+	// omitted, build metadata is ignored as per the semver specification.
+	// +kubebuilder:validation:Enum=asc;desc
+	// +optional
+	BuildMetadataOrder string `json:"buildMetadataOrder,omitempty"`
 }
 
 // AlphabeticalPolicy specifies a alphabetical ordering policy.
@@ -74,6 +177,10 @@ type AlphabeticalPolicy struct {
 	// +kubebuilder:validation:Enum=asc;desc
 	// +optional
 	Order string `json:"order,omitempty"`
+	// Natural compares the runs of digits in the tags numerically, so that
+	// e.g. 'build-10' is ordered after 'build-9'.
+	// +optional
+	Natural bool `json:"natural,omitempty"`
 }
 
 // NumericalPolicy specifies a numerical ordering policy.
@@ -85,6 +192,41 @@ type NumericalPolicy struct {
 	// +kubebuilder:validation:Enum=asc;desc
 	// +optional
 	Order string `json:"order,omitempty"`
+	// SkipInvalid makes the policy skip the tags which are not numbers,
+	// e.g. 'latest', instead of failing. The number of skipped tags is
+	// reported in the status.
+	// +optional
+	SkipInvalid bool `json:"skipInvalid,omitempty"`
+}
+
+// NewestPolicy specifies a policy selecting the most recent tag by time.
+type NewestPolicy struct {
+	// Source specifies the time used for ordering the tags. With 'firstSeen',
+	// the tag first seen last by a scan of the ImageRepository is selected.
+	// With 'created', the tag pointing at the most recently created image is
+	// selected; this requires the ImageRepository to reflect digests.
+	// +kubebuilder:default:="firstSeen"
+	// +kubebuilder:validation:Enum=firstSeen;created
+	// +optional
+	Source string `json:"source,omitempty"`
+}
+
+// CalVerPolicy specifies a calendar versioning policy.
+type CalVerPolicy struct {
+	// Format gives the format of the tags, made of the segments YYYY, YY, 0Y,
+	// MM, 0M, WW, 0W, DD, 0D, MAJOR, MINOR and MICRO as defined by
+	// https://calver.org, separated by any other characters, e.g.
+	// 'YYYY.0M.MICRO' or 'YYYY-0M-0D'. The format must have exactly one year
+	// segment. Tags not in the format are ignored.
+	// +required
+	Format string `json:"format"`
+	// MaxAge excludes the tags whose date is older than the given duration,
+	// e.g. '2160h' for 90 days. The date of a tag is the first day of the
+	// period it denotes.
+	// +kubebuilder:validation:Type=string
+	// +kubebuilder:validation:Pattern="^([0-9]+(\\.[0-9]+)?(ms|s|m|h))+$"
+	// +optional
+	MaxAge *metav1.Duration `json:"maxAge,omitempty"`
 }
 
 // TagFilter enables filtering tags based on a set of defined rules
@@ -97,24 +239,87 @@ type TagFilter struct {
 	// expression pattern, useful before tag evaluation.
 	// +optional
 	Extract string `json:"extract"`
+	// Exclude inverts the filter to drop the tags matching the pattern
+	// instead. Nothing is extracted from the remaining tags.
+	// +optional
+	Exclude bool `json:"exclude,omitempty"`
+}
+
+// TieBreaker orders the tags ranked equally by the policy of an ImagePolicy.
+type TieBreaker struct {
+	// Pattern specifies a regular expression pattern matching the original
+	// tags to order. Tags not matching the pattern are ranked last among
+	// equal tags.
+	// +optional
+	Pattern string `json:"pattern,omitempty"`
+	// Extract allows a capture group to be extracted from the specified
+	// regular expression pattern, to be ordered instead of the tags.
+	// +optional
+	Extract string `json:"extract,omitempty"`
+	// Policy gives the rule ordering the tags, or the values extracted from
+	// them.
+	// +required
+	Policy ImagePolicyChoice `json:"policy"`
 }
 
 // ImagePolicyStatus defines the observed state of ImagePolicy
 type ImagePolicyStatus struct {
 	// LatestImage gives the first in the list of images scanned by
 	// the image repository, when filtered and ordered according to
-	// the policy.
+	// the policy. When the image repository reflects digests, the image
+	// is pinned by digest, e.g. `repo:tag@sha256:...`.
 	LatestImage string `json:"latestImage,omitempty"`
 	// ObservedPreviousImage is the observed previous LatestImage. It is used
 	// to keep track of the previous and current images.
 	// +optional
 	ObservedPreviousImage string `json:"observedPreviousImage,omitempty"`
+	// Candidates lists the tags ranked highest by the policy, up to five,
+	// from the latest on. They show what would be selected next, e.g. if the
+	// latest tag is removed from the repository.
+	// +optional
+	Candidates []string `json:"candidates,omitempty"`
+	// Decision traces how the latest tag was selected.
+	// +optional
+	Decision *PolicyDecision `json:"decision,omitempty"`
+	// Pin is set when the policy is pinned to a tag.
+	// +optional
+	Pin *PinStatus `json:"pin,omitempty"`
 	// +optional
 	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
 	// +optional
 	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
+// PolicyDecision traces how the latest tag of an ImagePolicy was selected.
+type PolicyDecision struct {
+	// Tags is the number of tags of the ImageRepository read from the
+	// database.
+	Tags int64 `json:"tags"`
+	// Filtered is the number of tags satisfying the age constraints and
+	// passing the filters.
+	Filtered int64 `json:"filtered"`
+	// Invalid is the number of filtered tags the policy could not parse.
+	// +optional
+	Invalid int64 `json:"invalid,omitempty"`
+	// Ranked is the number of filtered tags considered by the policy, e.g.
+	// within the range of a semver policy, before the deny list is applied.
+	Ranked int64 `json:"ranked"`
+	// Value is the value extracted by the filters from the latest tag, and
+	// ordered by the policy. It is empty if the latest tag is pinned and
+	// doesn't pass the filters.
+	// +optional
+	Value string `json:"value,omitempty"`
+}
+
+// PinStatus reports the tag an ImagePolicy is pinned to.
+type PinStatus struct {
+	// Tag is the pinned tag.
+	Tag string `json:"tag"`
+	// PolicyTag is the tag the policy would select without the pin, if any.
+	// +optional
+	PolicyTag string `json:"policyTag,omitempty"`
+}
+
 // GetConditions returns the status conditions of the object.
 func (p ImagePolicy) GetConditions() []metav1.Condition {
 	return p.Status.Conditions
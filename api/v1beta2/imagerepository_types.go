@@ -92,6 +92,38 @@ type ImageRepositorySpec struct {
 	// +optional
 	ExclusionList []string `json:"exclusionList,omitempty"`
 
+	// InclusionList is a list of regex strings used to only store the tags
+	// matching at least one of them in the database. It is applied before the
+	// exclusion list. When empty, all the tags are included.
+	// +kubebuilder:validation:MaxItems:=25
+	// +optional
+	InclusionList []string `json:"inclusionList,omitempty"`
+
+	// Platforms is a list of platforms, as `os/arch[/variant]`, that the
+	// images must be available for. Only the tags pointing at an image, or an
+	// image index, matching all of them are stored in the database. Filtering
+	// by platform requires a request per tag to the registry on every scan.
+	// +kubebuilder:validation:MaxItems:=10
+	// +optional
+	Platforms []string `json:"platforms,omitempty"`
+
+	// ReflectDigests enables resolving the manifest digest of every scanned
+	// tag. The digests are recorded along with the tags, and the ImagePolicies
+	// referring to this ImageRepository report their latest image pinned by
+	// digest. Resolving the digests requires a request per tag to the
+	// registry on every scan. Defaults to false.
+	// +optional
+	ReflectDigests bool `json:"reflectDigests,omitempty"`
+
+	// ScanPageSize enables paginated scans, listing at most the given number
+	// of tags per request to the registry. The tags are stored page by page as
+	// they are listed, and a scan that is interrupted, e.g. by the timeout,
+	// resumes from the last stored tag on the next reconciliation. When not
+	// specified, all the tags are listed before being stored.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	ScanPageSize int `json:"scanPageSize,omitempty"`
+
 	// The provider used for authentication, can be 'aws', 'azure', 'gcp' or 'generic'.
 	// When not specified, defaults to 'generic'.
 	// +kubebuilder:validation:Enum=generic;aws;azure;gcp
@@ -104,6 +136,25 @@ type ScanResult struct {
 	TagCount   int         `json:"tagCount"`
 	ScanTime   metav1.Time `json:"scanTime,omitempty"`
 	LatestTags []string    `json:"latestTags,omitempty"`
+	// Unchanged is true if the scan found the same tags as the previous scan,
+	// in which case the stored tags were not rewritten.
+	Unchanged bool `json:"unchanged,omitempty"`
+}
+
+// TagMutation records a tag that was found to point at a different manifest
+// digest than in the previous scan.
+type TagMutation struct {
+	// Tag is the name of the mutated tag.
+	// +required
+	Tag string `json:"tag"`
+
+	// PreviousDigest is the digest the tag pointed at in the previous scan.
+	// +required
+	PreviousDigest string `json:"previousDigest"`
+
+	// Digest is the digest the tag pointed at in the last scan.
+	// +required
+	Digest string `json:"digest"`
 }
 
 // ImageRepositoryStatus defines the observed state of ImageRepository
@@ -130,6 +181,22 @@ type ImageRepositoryStatus struct {
 	// spec.lastScanResult.
 	ObservedExclusionList []string `json:"observedExclusionList,omitempty"`
 
+	// ObservedInclusionList is the inclusion list used for the observed scan
+	// result in status.lastScanResult.
+	// +optional
+	ObservedInclusionList []string `json:"observedInclusionList,omitempty"`
+
+	// ObservedPlatforms is the list of platforms used for the observed scan
+	// result in status.lastScanResult.
+	// +optional
+	ObservedPlatforms []string `json:"observedPlatforms,omitempty"`
+
+	// MutatedTags lists the tags that the last scan found to point at a
+	// different digest than in the previous scan. It is only populated when
+	// digests are reflected.
+	// +optional
+	MutatedTags []TagMutation `json:"mutatedTags,omitempty"`
+
 	meta.ReconcileRequestStatus `json:",inline"`
 }
 
@@ -43,6 +43,26 @@ func (in *AlphabeticalPolicy) DeepCopy() *AlphabeticalPolicy {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CalVerPolicy) DeepCopyInto(out *CalVerPolicy) {
+	*out = *in
+	if in.MaxAge != nil {
+		in, out := &in.MaxAge, &out.MaxAge
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CalVerPolicy.
+func (in *CalVerPolicy) DeepCopy() *CalVerPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(CalVerPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ImagePolicy) DeepCopyInto(out *ImagePolicy) {
 	*out = *in
@@ -76,7 +96,7 @@ func (in *ImagePolicyChoice) DeepCopyInto(out *ImagePolicyChoice) {
 	if in.SemVer != nil {
 		in, out := &in.SemVer, &out.SemVer
 		*out = new(SemVerPolicy)
-		**out = **in
+		(*in).DeepCopyInto(*out)
 	}
 	if in.Alphabetical != nil {
 		in, out := &in.Alphabetical, &out.Alphabetical
@@ -88,6 +108,16 @@ func (in *ImagePolicyChoice) DeepCopyInto(out *ImagePolicyChoice) {
 		*out = new(NumericalPolicy)
 		**out = **in
 	}
+	if in.Newest != nil {
+		in, out := &in.Newest, &out.Newest
+		*out = new(NewestPolicy)
+		**out = **in
+	}
+	if in.CalVer != nil {
+		in, out := &in.CalVer, &out.CalVer
+		*out = new(CalVerPolicy)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImagePolicyChoice.
@@ -142,6 +172,36 @@ func (in *ImagePolicySpec) DeepCopyInto(out *ImagePolicySpec) {
 		*out = new(TagFilter)
 		**out = **in
 	}
+	if in.Filters != nil {
+		in, out := &in.Filters, &out.Filters
+		*out = make([]TagFilter, len(*in))
+		copy(*out, *in)
+	}
+	if in.TieBreaker != nil {
+		in, out := &in.TieBreaker, &out.TieBreaker
+		*out = new(TieBreaker)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.MinAge != nil {
+		in, out := &in.MinAge, &out.MinAge
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.MaxAge != nil {
+		in, out := &in.MaxAge, &out.MaxAge
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.Deny != nil {
+		in, out := &in.Deny, &out.Deny
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Verify != nil {
+		in, out := &in.Verify, &out.Verify
+		*out = new(ImageVerification)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImagePolicySpec.
@@ -157,6 +217,21 @@ func (in *ImagePolicySpec) DeepCopy() *ImagePolicySpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ImagePolicyStatus) DeepCopyInto(out *ImagePolicyStatus) {
 	*out = *in
+	if in.Candidates != nil {
+		in, out := &in.Candidates, &out.Candidates
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Decision != nil {
+		in, out := &in.Decision, &out.Decision
+		*out = new(PolicyDecision)
+		**out = **in
+	}
+	if in.Pin != nil {
+		in, out := &in.Pin, &out.Pin
+		*out = new(PinStatus)
+		**out = **in
+	}
 	if in.Conditions != nil {
 		in, out := &in.Conditions, &out.Conditions
 		*out = make([]v1.Condition, len(*in))
@@ -264,6 +339,16 @@ func (in *ImageRepositorySpec) DeepCopyInto(out *ImageRepositorySpec) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.InclusionList != nil {
+		in, out := &in.InclusionList, &out.InclusionList
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Platforms != nil {
+		in, out := &in.Platforms, &out.Platforms
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageRepositorySpec.
@@ -296,6 +381,21 @@ func (in *ImageRepositoryStatus) DeepCopyInto(out *ImageRepositoryStatus) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.ObservedInclusionList != nil {
+		in, out := &in.ObservedInclusionList, &out.ObservedInclusionList
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ObservedPlatforms != nil {
+		in, out := &in.ObservedPlatforms, &out.ObservedPlatforms
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.MutatedTags != nil {
+		in, out := &in.MutatedTags, &out.MutatedTags
+		*out = make([]TagMutation, len(*in))
+		copy(*out, *in)
+	}
 	out.ReconcileRequestStatus = in.ReconcileRequestStatus
 }
 
@@ -309,6 +409,46 @@ func (in *ImageRepositoryStatus) DeepCopy() *ImageRepositoryStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImageVerification) DeepCopyInto(out *ImageVerification) {
+	*out = *in
+	if in.SecretRef != nil {
+		in, out := &in.SecretRef, &out.SecretRef
+		*out = new(meta.LocalObjectReference)
+		**out = **in
+	}
+	if in.MatchOIDCIdentity != nil {
+		in, out := &in.MatchOIDCIdentity, &out.MatchOIDCIdentity
+		*out = make([]OIDCIdentityMatch, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageVerification.
+func (in *ImageVerification) DeepCopy() *ImageVerification {
+	if in == nil {
+		return nil
+	}
+	out := new(ImageVerification)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NewestPolicy) DeepCopyInto(out *NewestPolicy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NewestPolicy.
+func (in *NewestPolicy) DeepCopy() *NewestPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(NewestPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *NumericalPolicy) DeepCopyInto(out *NumericalPolicy) {
 	*out = *in
@@ -324,6 +464,51 @@ func (in *NumericalPolicy) DeepCopy() *NumericalPolicy {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OIDCIdentityMatch) DeepCopyInto(out *OIDCIdentityMatch) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OIDCIdentityMatch.
+func (in *OIDCIdentityMatch) DeepCopy() *OIDCIdentityMatch {
+	if in == nil {
+		return nil
+	}
+	out := new(OIDCIdentityMatch)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PinStatus) DeepCopyInto(out *PinStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PinStatus.
+func (in *PinStatus) DeepCopy() *PinStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PinStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PolicyDecision) DeepCopyInto(out *PolicyDecision) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PolicyDecision.
+func (in *PolicyDecision) DeepCopy() *PolicyDecision {
+	if in == nil {
+		return nil
+	}
+	out := new(PolicyDecision)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ScanResult) DeepCopyInto(out *ScanResult) {
 	*out = *in
@@ -348,6 +533,11 @@ func (in *ScanResult) DeepCopy() *ScanResult {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SemVerPolicy) DeepCopyInto(out *SemVerPolicy) {
 	*out = *in
+	if in.Prereleases != nil {
+		in, out := &in.Prereleases, &out.Prereleases
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SemVerPolicy.
@@ -374,3 +564,34 @@ func (in *TagFilter) DeepCopy() *TagFilter {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TagMutation) DeepCopyInto(out *TagMutation) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TagMutation.
+func (in *TagMutation) DeepCopy() *TagMutation {
+	if in == nil {
+		return nil
+	}
+	out := new(TagMutation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TieBreaker) DeepCopyInto(out *TieBreaker) {
+	*out = *in
+	in.Policy.DeepCopyInto(&out.Policy)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TieBreaker.
+func (in *TieBreaker) DeepCopy() *TieBreaker {
+	if in == nil {
+		return nil
+	}
+	out := new(TieBreaker)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -16,16 +16,57 @@ limitations under the License.
 
 package controller
 
+import "time"
+
 // DatabaseWriter implementations record the tags for an image repository.
+//
+// SetDigests records the manifest digest of each tag, SetFirstSeen the time
+// each tag was first seen by a scan, and SetCreated the creation time of the
+// image each tag points at, all keyed by tag name.
+//
+// AppendPendingTags records a page of tags listed by a paginated scan that
+// hasn't completed yet, and ClearPendingTags removes all such pages once the
+// scan has completed.
+//
+// SetTagsValidator records the ETag of the tag list response, if any, and the
+// digest of the recorded tags, used to detect whether the tags changed.
+//
+// SetPlatforms records the platforms of the images referred to by manifest
+// digests, keyed by digest.
+//
+// Delete removes all the data recorded for an image repository.
 type DatabaseWriter interface {
 	SetTags(repo string, tags []string) error
+	SetDigests(repo string, digests map[string]string) error
+	SetFirstSeen(repo string, times map[string]time.Time) error
+	SetCreated(repo string, times map[string]time.Time) error
+	AppendPendingTags(repo string, tags []string) error
+	ClearPendingTags(repo string) error
+	SetTagsValidator(repo, etag, digest string) error
+	SetPlatforms(repo string, platforms map[string][]string) error
+	Delete(repo string) error
 }
 
 // DatabaseReader implementations get the stored set of tags for an image
 // repository.
 //
 // If no tags are availble for the repo, then implementations should return an
-// empty set of tags.
+// empty set of tags. The same applies to Digests, FirstSeen and Created, which
+// return the data recorded by the respective DatabaseWriter methods.
+// PendingTags returns the pages of tags appended by AppendPendingTags, in
+// order, and LastPendingTag the last tag of the last page, or an empty string,
+// without reading the other pages. TagsValidator returns the values recorded
+// by SetTagsValidator, or empty strings. Platforms returns the platforms
+// recorded by SetPlatforms. Repositories returns the image repositories with
+// recorded data.
 type DatabaseReader interface {
 	Tags(repo string) ([]string, error)
+	Digests(repo string) (map[string]string, error)
+	FirstSeen(repo string) (map[string]time.Time, error)
+	Created(repo string) (map[string]time.Time, error)
+	PendingTags(repo string) ([]string, error)
+	LastPendingTag(repo string) (string, error)
+	TagsValidator(repo string) (etag, digest string, err error)
+	Platforms(repo string) (map[string][]string, error)
+	Repositories() ([]string, error)
 }
@@ -0,0 +1,107 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	imagev1 "github.com/fluxcd/image-reflector-controller/api/v1beta2"
+)
+
+// DatabaseSweeper periodically removes the data of the image repositories no
+// longer scanned by any ImageRepository from the database, e.g. after the
+// image of an ImageRepository was changed.
+type DatabaseSweeper struct {
+	Client   client.Reader
+	Database interface {
+		DatabaseWriter
+		DatabaseReader
+	}
+	// Interval is the time between two sweeps.
+	Interval time.Duration
+	Logger   logr.Logger
+}
+
+// Start sweeps the database every interval until the context is cancelled.
+func (s *DatabaseSweeper) Start(ctx context.Context) error {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			deleted, err := s.Sweep(ctx)
+			if err != nil {
+				s.Logger.Error(err, "failed to sweep the database")
+				continue
+			}
+			if len(deleted) > 0 {
+				s.Logger.Info("removed unreferenced images from the database", "images", deleted)
+			}
+		}
+	}
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable. Only the
+// leader writes to the database.
+func (s *DatabaseSweeper) NeedLeaderElection() bool {
+	return true
+}
+
+// Sweep removes the data of the image repositories not referenced by any
+// ImageRepository from the database, and returns their names. The images of
+// the ImageRepositories not scanned yet are referenced too.
+func (s *DatabaseSweeper) Sweep(ctx context.Context) ([]string, error) {
+	// List the images in the database first, for the images of the
+	// ImageRepositories created in the meantime not to be removed.
+	names, err := s.Database.Repositories()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list images in database: %w", err)
+	}
+
+	var repos imagev1.ImageRepositoryList
+	if err := s.Client.List(ctx, &repos); err != nil {
+		return nil, fmt.Errorf("failed to list ImageRepositories: %w", err)
+	}
+	referenced := map[string]bool{}
+	for _, repo := range repos.Items {
+		if repo.Status.CanonicalImageName != "" {
+			referenced[repo.Status.CanonicalImageName] = true
+		}
+		if ref, err := parseImageReference(repo.Spec.Image); err == nil {
+			referenced[ref.Context().String()] = true
+		}
+	}
+
+	var deleted []string
+	for _, name := range names {
+		if referenced[name] {
+			continue
+		}
+		if err := s.Database.Delete(name); err != nil {
+			return deleted, fmt.Errorf("failed to delete %q from database: %w", name, err)
+		}
+		deleted = append(deleted, name)
+	}
+	return deleted, nil
+}
@@ -0,0 +1,56 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	imagev1 "github.com/fluxcd/image-reflector-controller/api/v1beta2"
+)
+
+func TestDatabaseSweeper_Sweep(t *testing.T) {
+	g := NewWithT(t)
+
+	scanned := &imagev1.ImageRepository{}
+	scanned.Name = "scanned"
+	scanned.Namespace = "default"
+	scanned.Spec.Image = "example.com/foo/new"
+	scanned.Status.CanonicalImageName = "example.com/foo/old"
+	notScanned := &imagev1.ImageRepository{}
+	notScanned.Name = "not-scanned"
+	notScanned.Namespace = "default"
+	notScanned.Spec.Image = "alpine"
+
+	db := &mockDatabase{RepoData: []string{
+		"example.com/foo/old", "example.com/foo/deleted", "index.docker.io/library/alpine",
+	}}
+	s := &DatabaseSweeper{
+		Client:   fake.NewClientBuilder().WithObjects(scanned, notScanned).Build(),
+		Database: db,
+		Logger:   logr.Discard(),
+	}
+
+	deleted, err := s.Sweep(context.TODO())
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(deleted).To(Equal([]string{"example.com/foo/deleted"}))
+	g.Expect(db.DeletedRepos).To(Equal([]string{"example.com/foo/deleted"}))
+}
@@ -18,11 +18,18 @@ package controller
 
 import (
 	"context"
+	"crypto"
 	"errors"
 	"fmt"
+	"regexp"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/sigstore/cosign/v2/pkg/cosign"
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
@@ -47,7 +54,9 @@ import (
 	pkgreconcile "github.com/fluxcd/pkg/runtime/reconcile"
 
 	imagev1 "github.com/fluxcd/image-reflector-controller/api/v1beta2"
+	"github.com/fluxcd/image-reflector-controller/internal/evaluation"
 	"github.com/fluxcd/image-reflector-controller/internal/policy"
+	"github.com/fluxcd/image-reflector-controller/internal/verify"
 )
 
 // errAccessDenied is returned when an ImageRepository reference in ImagePolicy
@@ -71,14 +80,57 @@ func (e errInvalidPolicy) Error() string {
 	return e.err.Error()
 }
 
+// errVerificationFailed is returned when none of the candidate images of a
+// policy has a valid signature.
+type errVerificationFailed struct {
+	err error
+}
+
+// Error implements the error interface.
+func (e errVerificationFailed) Error() string {
+	return e.err.Error()
+}
+
+// errNoEligibleTags is returned when none of the tags of a policy satisfies its
+// age constraints.
+type errNoEligibleTags struct {
+	err error
+	// requeueAfter is the duration until a tag becomes old enough, if any.
+	requeueAfter time.Duration
+}
+
+// Error implements the error interface.
+func (e errNoEligibleTags) Error() string {
+	return e.err.Error()
+}
+
 var errNoTagsInDatabase = errors.New("no tags in database")
 
+// errNoTagMatches is returned when the policy selects no tag, as the policies
+// do when none of the tags matches.
+var errNoTagMatches = errors.New("unable to determine latest version from provided list")
+
+// maxVerifyCandidates is the maximum number of candidate tags, in the order of
+// the policy, whose signatures are verified before giving up.
+const maxVerifyCandidates = 10
+
+// maxStatusCandidates is the maximum number of candidate tags reported in the
+// status of an ImagePolicy.
+const maxStatusCandidates = 5
+
+// RegistryOptionsGetter returns the options required to access the registry
+// of an ImageRepository.
+type RegistryOptionsGetter interface {
+	RegistryOptions(ctx context.Context, repo *imagev1.ImageRepository) ([]remote.Option, error)
+}
+
 // imagePolicyOwnedConditions is a list of conditions owned by the
 // ImagePolicyReconciler.
 var imagePolicyOwnedConditions = []string{
 	meta.ReadyCondition,
 	meta.ReconcilingCondition,
 	meta.StalledCondition,
+	imagev1.VerifiedCondition,
 }
 
 // imagePolicyNegativeConditions is a list of negative polarity conditions
@@ -98,6 +150,7 @@ const imageRepoKey = ".spec.imageRepository"
 // +kubebuilder:rbac:groups=image.toolkit.fluxcd.io,resources=imagepolicies/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=image.toolkit.fluxcd.io,resources=imagerepositories,verbs=get;list;watch
 // +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
 // +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 // ImagePolicyReconciler reconciles a ImagePolicy object
@@ -109,6 +162,12 @@ type ImagePolicyReconciler struct {
 	ControllerName string
 	Database       DatabaseReader
 	ACLOptions     acl.Options
+	// RegistryOptions gives the options to access the registries when
+	// verifying the signatures of images.
+	RegistryOptions RegistryOptionsGetter
+	// KeylessTrustedRoot holds the roots trusted to verify keyless
+	// signatures. Keyless verification fails when nil.
+	KeylessTrustedRoot *verify.TrustedRoot
 
 	patchOptions []patch.Option
 }
@@ -212,8 +271,9 @@ func (r *ImagePolicyReconciler) reconcile(ctx context.Context, sp *patch.SerialP
 	var resultImage, resultTag, previousTag string
 
 	// If there's no error and no requeue is requested, it's a success. Unlike
-	// other reconcilers, this reconciler doesn't requeue on its own with a
-	// RequeueAfter value.
+	// other reconcilers, this reconciler only requeues on its own with a
+	// RequeueAfter value when the tags eligible under the age constraints of
+	// the policy change.
 	isSuccess := func(res ctrl.Result, err error) bool {
 		if err != nil || res.Requeue {
 			return false
@@ -223,6 +283,9 @@ func (r *ImagePolicyReconciler) reconcile(ctx context.Context, sp *patch.SerialP
 
 	defer func() {
 		readyMsg := composeImagePolicyReadyMessage(previousTag, resultTag, resultImage)
+		if pin := obj.Status.Pin; pin != nil {
+			readyMsg = fmt.Sprintf("%s (pinned, the policy selects %s)", readyMsg, pin.PolicyTag)
+		}
 
 		rs := pkgreconcile.NewResultFinalizer(isSuccess, readyMsg)
 		retErr = rs.Finalize(obj, result, retErr)
@@ -254,6 +317,9 @@ func (r *ImagePolicyReconciler) reconcile(ctx context.Context, sp *patch.SerialP
 
 	// Cleanup the last result.
 	obj.Status.LatestImage = ""
+	obj.Status.Candidates = nil
+	obj.Status.Pin = nil
+	obj.Status.Decision = nil
 
 	// Get ImageRepository from reference.
 	repo, err := r.getImageRepository(ctx, obj)
@@ -290,7 +356,7 @@ func (r *ImagePolicyReconciler) reconcile(ctx context.Context, sp *patch.SerialP
 	// Construct a policer from the spec.policy.
 	// Read the tags from database and use the policy to obtain a result for the
 	// latest tag.
-	latest, err := r.applyPolicy(ctx, obj, repo)
+	res, err := r.applyPolicy(ctx, obj, repo)
 	if err != nil {
 		// Stall if it's an invalid policy.
 		if _, ok := err.(errInvalidPolicy); ok {
@@ -299,6 +365,23 @@ func (r *ImagePolicyReconciler) reconcile(ctx context.Context, sp *patch.SerialP
 			return
 		}
 
+		// If no candidate image has a valid signature, mark not verified and
+		// retry.
+		if _, ok := err.(errVerificationFailed); ok {
+			conditions.MarkFalse(obj, imagev1.VerifiedCondition, imagev1.VerificationFailedReason, err.Error())
+			conditions.MarkFalse(obj, meta.ReadyCondition, imagev1.VerificationFailedReason, err.Error())
+			result, retErr = ctrl.Result{}, err
+			return
+		}
+
+		// If all the tags are too new or too old, mark not ready and requeue
+		// when a tag becomes old enough, if any.
+		if e, ok := err.(errNoEligibleTags); ok {
+			conditions.MarkFalse(obj, meta.ReadyCondition, imagev1.NoEligibleTagsReason, e.Error())
+			result, retErr = ctrl.Result{RequeueAfter: e.requeueAfter}, nil
+			return
+		}
+
 		// If there's no tag in the database, mark not ready and retry.
 		if err == errNoTagsInDatabase {
 			conditions.MarkFalse(obj, meta.ReadyCondition, imagev1.DependencyNotReadyReason, err.Error())
@@ -311,8 +394,19 @@ func (r *ImagePolicyReconciler) reconcile(ctx context.Context, sp *patch.SerialP
 		return
 	}
 
+	// Pin the latest image by digest if the ImageRepository reflects digests.
+	latestImage, err := r.pinImage(repo, res.latest)
+	if err != nil {
+		conditions.MarkFalse(obj, meta.ReadyCondition, metav1.StatusFailure, err.Error())
+		result, retErr = ctrl.Result{}, err
+		return
+	}
+
 	// Write the observations on status.
-	obj.Status.LatestImage = repo.Spec.Image + ":" + latest
+	obj.Status.LatestImage = latestImage
+	obj.Status.Candidates = res.candidates
+	obj.Status.Pin = res.pin
+	obj.Status.Decision = res.decision
 	// If the old latest image and new latest image don't match, set the old
 	// image as the observed previous image.
 	// NOTE: The following allows the previous image to be set empty when
@@ -325,7 +419,7 @@ func (r *ImagePolicyReconciler) reconcile(ctx context.Context, sp *patch.SerialP
 	// Parse the observed previous image if any and extract previous tag. This
 	// is used to determine image tag update path.
 	if obj.Status.ObservedPreviousImage != "" {
-		prevRef, err := name.NewTag(obj.Status.ObservedPreviousImage)
+		prevRef, err := name.NewTag(trimDigest(obj.Status.ObservedPreviousImage))
 		if err != nil {
 			e := fmt.Errorf("failed to parse previous image '%s': %w", obj.Status.ObservedPreviousImage, err)
 			conditions.MarkFalse(obj, meta.ReadyCondition, meta.FailedReason, e.Error())
@@ -335,11 +429,16 @@ func (r *ImagePolicyReconciler) reconcile(ctx context.Context, sp *patch.SerialP
 	}
 
 	resultImage = repo.Spec.Image
-	resultTag = latest
+	resultTag = res.latest
 
+	if obj.Spec.Verify != nil {
+		conditions.MarkTrue(obj, imagev1.VerifiedCondition, meta.SucceededReason, "verified signature of '%s'", latestImage)
+	} else {
+		conditions.Delete(obj, imagev1.VerifiedCondition)
+	}
 	conditions.Delete(obj, meta.ReadyCondition)
 
-	result, retErr = ctrl.Result{}, nil
+	result, retErr = ctrl.Result{RequeueAfter: res.requeueAfter}, nil
 	return
 }
 
@@ -379,41 +478,433 @@ func (r *ImagePolicyReconciler) getImageRepository(ctx context.Context, obj *ima
 	return repo, nil
 }
 
+// policyResult is the result of applying an ImagePolicy.
+type policyResult struct {
+	// latest is the latest tag.
+	latest string
+	// candidates are the highest ranked tags.
+	candidates []string
+	// requeueAfter is the duration until the tags satisfying the age
+	// constraints of the policy change, if they may.
+	requeueAfter time.Duration
+	// pin is set when latest is the pinned tag of the policy.
+	pin *imagev1.PinStatus
+	// decision traces the selection of latest.
+	decision *imagev1.PolicyDecision
+}
+
 // applyPolicy reads the tags of the given repository from the internal database
-// and applies the tag filters and constraints to return the latest image.
-func (r *ImagePolicyReconciler) applyPolicy(ctx context.Context, obj *imagev1.ImagePolicy, repo *imagev1.ImageRepository) (string, error) {
-	policer, err := policy.PolicerFromSpec(obj.Spec.Policy)
+// and applies the tag filters and constraints to return the latest image, the
+// highest ranked candidate tags and the trace of the decision. If a tag is
+// pinned, it is returned as the latest instead.
+func (r *ImagePolicyReconciler) applyPolicy(ctx context.Context, obj *imagev1.ImagePolicy, repo *imagev1.ImageRepository) (policyResult, error) {
+	// Construct the policer from the filters, policy and tie-breaker.
+	policer, err := policy.CompositeFromSpec(obj.Spec)
 	if err != nil {
-		return "", errInvalidPolicy{err: fmt.Errorf("invalid policy: %w", err)}
+		return policyResult{}, errInvalidPolicy{err: fmt.Errorf("invalid policy: %w", err)}
 	}
 
 	// Read tags from database, apply and filter is configured and compute the
 	// result.
 	tags, err := r.Database.Tags(repo.Status.CanonicalImageName)
 	if err != nil {
-		return "", fmt.Errorf("failed to read tags from database: %w", err)
+		return policyResult{}, fmt.Errorf("failed to read tags from database: %w", err)
 	}
 
 	if len(tags) == 0 {
-		return "", errNoTagsInDatabase
+		return policyResult{}, errNoTagsInDatabase
+	}
+
+	// Rank the tags even if a tag is pinned, to report what the policy would
+	// select.
+	res, ranked, err := r.rankTags(obj, repo, policer, tags)
+	if obj.Spec.Pin == "" {
+		if err != nil {
+			return policyResult{}, err
+		}
+		if obj.Spec.Verify != nil {
+			if res.latest, err = r.latestVerified(ctx, obj, repo, ranked); err != nil {
+				return policyResult{}, err
+			}
+			res.decision.Value = policer.Values([]string{res.latest})[res.latest]
+		}
+		// Never report an empty tag as the latest image.
+		if res.latest == "" {
+			return policyResult{}, errNoTagMatches
+		}
+		return res, nil
+	}
+
+	// The pinned tag is selected as long as it exists and is not denied,
+	// whether the policy selects a tag or not, except if the policy is
+	// invalid.
+	if _, ok := err.(errInvalidPolicy); ok {
+		return policyResult{}, err
+	}
+	pin := obj.Spec.Pin
+	found := false
+	for _, tag := range tags {
+		if tag == pin {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return policyResult{}, fmt.Errorf("pinned tag '%s' not found in the tags of '%s'", pin, repo.Spec.Image)
+	}
+	denied, err := r.deniedTags(obj, repo, []string{pin})
+	if err != nil {
+		return policyResult{}, err
+	}
+	if denied[pin] {
+		return policyResult{}, errInvalidPolicy{err: fmt.Errorf("invalid policy: pinned tag '%s' is denied", pin)}
+	}
+	res.pin = &imagev1.PinStatus{Tag: pin, PolicyTag: res.latest}
+	res.latest = pin
+	if res.decision == nil {
+		res.decision = &imagev1.PolicyDecision{Tags: int64(len(tags))}
+	}
+	res.decision.Value = policer.Values([]string{pin})[pin]
+	if obj.Spec.Verify != nil {
+		if _, err := r.latestVerified(ctx, obj, repo, []string{pin}); err != nil {
+			return policyResult{}, err
+		}
+	}
+	return res, nil
+}
+
+// rankTags applies the age constraints, the given policer and the deny list of
+// the given policy to the given tags of the repository. It returns the result
+// of the policy and all the ranked tags.
+func (r *ImagePolicyReconciler) rankTags(obj *imagev1.ImagePolicy, repo *imagev1.ImageRepository, policer *policy.Composite, tags []string) (policyResult, []string, error) {
+	decision := &imagev1.PolicyDecision{Tags: int64(len(tags))}
+
+	// Exclude the tags too new or too old by the times they were first seen.
+	var minAge, maxAge time.Duration
+	if obj.Spec.MinAge != nil {
+		minAge = obj.Spec.MinAge.Duration
+	}
+	if obj.Spec.MaxAge != nil {
+		maxAge = obj.Spec.MaxAge.Duration
+	}
+	var requeueAfter time.Duration
+	if minAge > 0 || maxAge > 0 {
+		if maxAge > 0 && minAge >= maxAge {
+			return policyResult{}, nil, errInvalidPolicy{err: fmt.Errorf("invalid policy: minimum age %s must be less than maximum age %s", minAge, maxAge)}
+		}
+		firstSeen, err := r.Database.FirstSeen(repo.Status.CanonicalImageName)
+		if err != nil {
+			return policyResult{}, nil, fmt.Errorf("failed to read tag times from database: %w", err)
+		}
+		tags, requeueAfter = filterByAge(tags, firstSeen, minAge, maxAge, time.Now())
+		if len(tags) == 0 {
+			return policyResult{}, nil, errNoEligibleTags{
+				err:          fmt.Errorf("none of the tags satisfies the age constraints"),
+				requeueAfter: requeueAfter,
+			}
+		}
+	}
+
+	// Time based policies order the tags by the times recorded in the
+	// database.
+	var err error
+	for _, p := range []policy.Policer{policer.Primary, policer.TieBreaker} {
+		newest, ok := p.(*policy.Newest)
+		if !ok {
+			continue
+		}
+		switch newest.Source {
+		case policy.NewestSourceCreated:
+			newest.Times, err = r.Database.Created(repo.Status.CanonicalImageName)
+		default:
+			newest.Times, err = r.Database.FirstSeen(repo.Status.CanonicalImageName)
+		}
+		if err != nil {
+			return policyResult{}, nil, fmt.Errorf("failed to read tag times from database: %w", err)
+		}
+	}
+
+	values := policer.Values(tags)
+	decision.Filtered = int64(len(values))
+	decision.Invalid = int64(policer.Skipped(tags))
+
+	ranked, err := policer.Rank(tags)
+	if err != nil {
+		return policyResult{}, nil, err
+	}
+	if len(ranked) == 0 {
+		return policyResult{}, nil, errNoTagMatches
+	}
+	decision.Ranked = int64(len(ranked))
+
+	// Exclude the denied tags from the ranked ones.
+	denied, err := r.deniedTags(obj, repo, ranked)
+	if err != nil {
+		return policyResult{}, nil, err
+	}
+	if len(denied) > 0 {
+		allowed := make([]string, 0, len(ranked)-len(denied))
+		for _, tag := range ranked {
+			if !denied[tag] {
+				allowed = append(allowed, tag)
+			}
+		}
+		if len(allowed) == 0 {
+			return policyResult{}, nil, fmt.Errorf("all the tags selected by the policy are denied")
+		}
+		ranked = allowed
+	}
+
+	decision.Value = values[ranked[0]]
+	res := policyResult{
+		latest:       ranked[0],
+		candidates:   ranked,
+		requeueAfter: requeueAfter,
+		decision:     decision,
+	}
+	if len(res.candidates) > maxStatusCandidates {
+		res.candidates = res.candidates[:maxStatusCandidates]
+	}
+	return res, ranked, nil
+}
+
+// EvaluatePolicy implements evaluation.Evaluator. The tags are ranked as by the
+// ImagePolicies, ordered by the times recorded in the database for the given
+// canonical repository, if any. The age constraints, pin, deny list and
+// verification of the spec are not evaluated.
+func (r *ImagePolicyReconciler) EvaluatePolicy(spec imagev1.ImagePolicySpec, repo string, tags []string) (*evaluation.Evaluation, error) {
+	policer, err := policy.CompositeFromSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &evaluation.Evaluation{
+		Matched: []string{},
+		Values:  policer.Values(tags),
+		Ranked:  []string{},
+		Skipped: policer.Skipped(tags),
+	}
+	for tag := range e.Values {
+		e.Matched = append(e.Matched, tag)
 	}
+	sort.Strings(e.Matched)
 
-	// Apply tag filter.
-	if obj.Spec.FilterTags != nil {
-		filter, err := policy.NewRegexFilter(obj.Spec.FilterTags.Pattern, obj.Spec.FilterTags.Extract)
+	obj := &imagev1.ImagePolicy{Spec: spec}
+	obj.Spec.MinAge, obj.Spec.MaxAge, obj.Spec.Deny = nil, nil, nil
+	imageRepo := &imagev1.ImageRepository{}
+	imageRepo.Status.CanonicalImageName = repo
+	res, ranked, err := r.rankTags(obj, imageRepo, policer, tags)
+	if err != nil {
+		e.Error = err.Error()
+		return e, nil
+	}
+	e.Ranked = ranked
+	e.Latest = res.latest
+	return e, nil
+}
+
+// deniedTags returns the given tags of the repository denied by the policy,
+// by tag or by the digest recorded in the internal database.
+func (r *ImagePolicyReconciler) deniedTags(obj *imagev1.ImagePolicy, repo *imagev1.ImageRepository, tags []string) (map[string]bool, error) {
+	if len(obj.Spec.Deny) == 0 {
+		return nil, nil
+	}
+	deny := map[string]bool{}
+	byDigest := false
+	for _, item := range obj.Spec.Deny {
+		deny[item] = true
+		// Tags can't contain colons, unlike digests.
+		if strings.Contains(item, ":") {
+			byDigest = true
+		}
+	}
+	var digests map[string]string
+	if byDigest {
+		var err error
+		digests, err = r.Database.Digests(repo.Status.CanonicalImageName)
 		if err != nil {
-			return "", errInvalidPolicy{err: fmt.Errorf("failed to filter tags: %w", err)}
+			return nil, fmt.Errorf("failed to read digests from database: %w", err)
+		}
+	}
+
+	denied := map[string]bool{}
+	for _, tag := range tags {
+		if deny[tag] {
+			denied[tag] = true
+			continue
+		}
+		if digest, ok := digests[tag]; ok && deny[digest] {
+			denied[tag] = true
+		}
+	}
+	return denied, nil
+}
+
+// filterByAge returns the given tags first seen at least minAge and less than
+// maxAge before now, when not zero. The age of the tags without a first seen
+// time, or with the zero time, is unknown: they predate the first scan, so
+// they are old enough for minAge, but may be too old for maxAge, and are
+// excluded when it's set. It also returns the duration until a tag becomes old
+// enough or too old, or zero if none does.
+func filterByAge(tags []string, firstSeen map[string]time.Time, minAge, maxAge time.Duration, now time.Time) ([]string, time.Duration) {
+	var eligible []string
+	var next time.Duration
+	wait := func(d time.Duration) {
+		if next == 0 || d < next {
+			next = d
 		}
-		filter.Apply(tags)
-		tags = filter.Items()
-		latest, err := policer.Latest(tags)
+	}
+	for _, tag := range tags {
+		seen, ok := firstSeen[tag]
+		if !ok || seen.IsZero() {
+			if maxAge == 0 {
+				eligible = append(eligible, tag)
+			}
+			continue
+		}
+		age := now.Sub(seen)
+		if maxAge > 0 && age >= maxAge {
+			continue
+		}
+		if age < minAge {
+			wait(minAge - age)
+			continue
+		}
+		if maxAge > 0 {
+			wait(maxAge - age)
+		}
+		eligible = append(eligible, tag)
+	}
+	return eligible, next
+}
+
+// latestVerified returns the first of the given ranked tags whose image has a
+// valid signature. Up to maxVerifyCandidates candidates are verified.
+func (r *ImagePolicyReconciler) latestVerified(ctx context.Context, obj *imagev1.ImagePolicy, repo *imagev1.ImageRepository, ranked []string) (string, error) {
+	if r.RegistryOptions == nil {
+		return "", fmt.Errorf("verification of signatures is not supported")
+	}
+	ref, err := name.ParseReference(repo.Spec.Image)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse image '%s': %w", repo.Spec.Image, err)
+	}
+	options, err := r.RegistryOptions.RegistryOptions(ctx, repo)
+	if err != nil {
+		return "", fmt.Errorf("failed to configure access to the registry: %w", err)
+	}
+	options = append(options, remote.WithContext(ctx))
+	verifier, err := r.verifier(ctx, obj, options)
+	if err != nil {
+		return "", err
+	}
+
+	var digests map[string]string
+	if repo.Spec.ReflectDigests {
+		digests, err = r.Database.Digests(repo.Status.CanonicalImageName)
 		if err != nil {
-			return "", err
+			return "", fmt.Errorf("failed to read digests from database: %w", err)
+		}
+	}
+
+	if len(ranked) > maxVerifyCandidates {
+		ranked = ranked[:maxVerifyCandidates]
+	}
+	for _, tag := range ranked {
+		// Verify the image pinned by the policy, if any, not whatever the tag
+		// points at now.
+		var image name.Reference = ref.Context().Tag(tag)
+		if digest, ok := digests[tag]; ok {
+			image = ref.Context().Digest(digest)
+		}
+		err = verifier.Verify(ctx, image)
+		if err == nil {
+			return tag, nil
+		}
+		if !errors.Is(err, verify.ErrNoValidSignature) {
+			return "", fmt.Errorf("failed to verify the signature of '%s:%s': %w", repo.Spec.Image, tag, err)
+		}
+	}
+	return "", errVerificationFailed{
+		err: fmt.Errorf("no valid signature found for the candidate tags of '%s': %s", repo.Spec.Image, strings.Join(ranked, ", ")),
+	}
+}
+
+// verifier returns the verifier of the signatures of the images of the given
+// policy.
+func (r *ImagePolicyReconciler) verifier(ctx context.Context, obj *imagev1.ImagePolicy, options []remote.Option) (*verify.CosignVerifier, error) {
+	spec := obj.Spec.Verify
+	if spec.SecretRef != nil {
+		var keySecret corev1.Secret
+		if err := r.Get(ctx, types.NamespacedName{
+			Namespace: obj.GetNamespace(),
+			Name:      spec.SecretRef.Name,
+		}, &keySecret); err != nil {
+			return nil, fmt.Errorf("failed to get verification secret '%s': %w", spec.SecretRef.Name, err)
 		}
-		return filter.GetOriginalTag(latest), nil
+		var keys []crypto.PublicKey
+		for k, data := range keySecret.Data {
+			if !strings.HasSuffix(k, ".pub") {
+				continue
+			}
+			pub, err := verify.ParsePublicKeys(data)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse key '%s' of verification secret '%s': %w", k, spec.SecretRef.Name, err)
+			}
+			keys = append(keys, pub...)
+		}
+		if len(keys) == 0 {
+			return nil, fmt.Errorf("no public keys found in verification secret '%s'", spec.SecretRef.Name)
+		}
+		return verify.NewKeyVerifier(keys, options...)
+	}
+
+	// Fulcio issues certificates to any identity, the signatures of any
+	// identity must not be trusted.
+	if len(spec.MatchOIDCIdentity) == 0 {
+		return nil, errInvalidPolicy{err: errors.New("keyless verification requires at least one OIDC identity to match")}
+	}
+	identities := make([]cosign.Identity, len(spec.MatchOIDCIdentity))
+	for i, match := range spec.MatchOIDCIdentity {
+		if _, err := regexp.Compile(match.Issuer); err != nil {
+			return nil, errInvalidPolicy{err: fmt.Errorf("invalid OIDC issuer pattern '%s': %w", match.Issuer, err)}
+		}
+		if _, err := regexp.Compile(match.Subject); err != nil {
+			return nil, errInvalidPolicy{err: fmt.Errorf("invalid OIDC subject pattern '%s': %w", match.Subject, err)}
+		}
+		identities[i] = cosign.Identity{IssuerRegExp: match.Issuer, SubjectRegExp: match.Subject}
+	}
+	if r.KeylessTrustedRoot == nil {
+		return nil, fmt.Errorf("keyless verification is not configured")
+	}
+	return verify.NewKeylessVerifier(r.KeylessTrustedRoot, identities, options...)
+}
+
+// pinImage returns the image of the given repository with the given tag. If the
+// repository reflects digests, the image is pinned by the digest of the tag
+// recorded in the internal database, e.g. `repo:tag@sha256:...`. If no digest
+// is recorded for the tag, the image is returned unpinned.
+func (r *ImagePolicyReconciler) pinImage(repo *imagev1.ImageRepository, tag string) (string, error) {
+	image := repo.Spec.Image + ":" + tag
+	if !repo.Spec.ReflectDigests {
+		return image, nil
+	}
+
+	digests, err := r.Database.Digests(repo.Status.CanonicalImageName)
+	if err != nil {
+		return "", fmt.Errorf("failed to read digests from database: %w", err)
+	}
+	if digest, ok := digests[tag]; ok {
+		image = image + "@" + digest
+	}
+	return image, nil
+}
+
+// trimDigest removes the digest, if any, from the given image reference.
+func trimDigest(image string) string {
+	if i := strings.Index(image, "@"); i >= 0 {
+		return image[:i]
 	}
-	// Compute and return result.
-	return policer.Latest(tags)
+	return image
 }
 
 // reconcileDelete handles the deletion of the object.
@@ -18,12 +18,18 @@ package controller
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"errors"
 	"testing"
+	"time"
 
 	aclapis "github.com/fluxcd/pkg/apis/acl"
 	"github.com/fluxcd/pkg/apis/meta"
 	"github.com/fluxcd/pkg/runtime/acl"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
 	. "github.com/onsi/gomega"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -31,7 +37,10 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
 	imagev1 "github.com/fluxcd/image-reflector-controller/api/v1beta2"
+	"github.com/fluxcd/image-reflector-controller/internal/evaluation"
 	"github.com/fluxcd/image-reflector-controller/internal/policy"
+	"github.com/fluxcd/image-reflector-controller/internal/test"
+	"github.com/fluxcd/image-reflector-controller/internal/verify"
 )
 
 func TestImagePolicyReconciler_getImageRepository(t *testing.T) {
@@ -226,12 +235,21 @@ func TestImagePolicyReconciler_getImageRepository(t *testing.T) {
 
 func TestImagePolicyReconciler_applyPolicy(t *testing.T) {
 	tests := []struct {
-		name       string
-		policy     imagev1.ImagePolicyChoice
-		filter     *imagev1.TagFilter
-		db         *mockDatabase
-		wantErr    bool
-		wantResult string
+		name           string
+		policy         imagev1.ImagePolicyChoice
+		filter         *imagev1.TagFilter
+		filters        []imagev1.TagFilter
+		tieBreaker     *imagev1.TieBreaker
+		minAge         *metav1.Duration
+		maxAge         *metav1.Duration
+		pin            string
+		deny           []string
+		db             *mockDatabase
+		wantErr        bool
+		wantResult     string
+		wantCandidates []string
+		wantPin        *imagev1.PinStatus
+		wantDecision   *imagev1.PolicyDecision
 	}{
 		{
 			name:    "invalid policy",
@@ -251,10 +269,24 @@ func TestImagePolicyReconciler_applyPolicy(t *testing.T) {
 			wantErr: true,
 		},
 		{
-			name:       "semver, no tag filter",
-			policy:     imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Range: "1.0.x"}},
-			db:         &mockDatabase{TagData: []string{"1.0.0", "2.0.0", "1.0.1", "1.2.0"}},
-			wantResult: "1.0.1",
+			name:    "empty tag selected",
+			policy:  imagev1.ImagePolicyChoice{Alphabetical: &imagev1.AlphabeticalPolicy{}},
+			db:      &mockDatabase{TagData: []string{""}},
+			wantErr: true,
+		},
+		{
+			name:           "semver, no tag filter",
+			policy:         imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Range: "1.0.x"}},
+			db:             &mockDatabase{TagData: []string{"1.0.0", "2.0.0", "1.0.1", "1.2.0"}},
+			wantResult:     "1.0.1",
+			wantCandidates: []string{"1.0.1", "1.0.0"},
+		},
+		{
+			name:           "semver, more candidates than reported",
+			policy:         imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Range: ">=1.0.0"}},
+			db:             &mockDatabase{TagData: []string{"1.0.0", "1.1.0", "1.2.0", "1.3.0", "1.4.0", "1.5.0", "2.0.0"}},
+			wantResult:     "2.0.0",
+			wantCandidates: []string{"2.0.0", "1.5.0", "1.4.0", "1.3.0", "1.2.0"},
 		},
 		{
 			name:       "semver with 'v' prefix, no tag filter",
@@ -291,7 +323,30 @@ func TestImagePolicyReconciler_applyPolicy(t *testing.T) {
 			db: &mockDatabase{TagData: []string{
 				"1.0.0", "1.0.0-rc.1", "1.0.0-rc.2", "1.0.0-rc.3", "1.0.1-rc.2",
 			}},
-			wantResult: "1.0.0-rc.3",
+			wantResult:     "1.0.0-rc.3",
+			wantCandidates: []string{"1.0.0-rc.3", "1.0.0-rc.2", "1.0.0-rc.1"},
+			wantDecision:   &imagev1.PolicyDecision{Tags: 5, Filtered: 3, Ranked: 3, Value: "3"},
+		},
+		{
+			name:   "numerical policy with invalid tags",
+			policy: imagev1.ImagePolicyChoice{Numerical: &imagev1.NumericalPolicy{}},
+			db: &mockDatabase{TagData: []string{
+				"20230101120000000001", "20230101120000000002", "latest",
+			}},
+			wantErr: true,
+		},
+		{
+			name:   "numerical policy skipping invalid tags",
+			policy: imagev1.ImagePolicyChoice{Numerical: &imagev1.NumericalPolicy{SkipInvalid: true}},
+			filter: &imagev1.TagFilter{
+				Pattern: "^[^v]",
+			},
+			db: &mockDatabase{TagData: []string{
+				"20230101120000000001", "20230101120000000002", "latest", "main", "v1",
+			}},
+			wantResult:     "20230101120000000002",
+			wantCandidates: []string{"20230101120000000002", "20230101120000000001"},
+			wantDecision:   &imagev1.PolicyDecision{Tags: 5, Filtered: 4, Invalid: 2, Ranked: 2, Value: "20230101120000000002"},
 		},
 		{
 			name:   "valid tag filter with alphabetical policy",
@@ -305,6 +360,165 @@ func TestImagePolicyReconciler_applyPolicy(t *testing.T) {
 			}},
 			wantResult: "foo-zzz",
 		},
+		{
+			name:   "newest policy by first seen time",
+			policy: imagev1.ImagePolicyChoice{Newest: &imagev1.NewestPolicy{}},
+			db: &mockDatabase{
+				TagData: []string{"aaa", "bbb", "ccc"},
+				FirstSeenData: map[string]time.Time{
+					"aaa": time.Unix(300, 0),
+					"bbb": time.Unix(200, 0),
+					"ccc": time.Unix(100, 0),
+				},
+			},
+			wantResult: "aaa",
+		},
+		{
+			name:   "newest policy by created time",
+			policy: imagev1.ImagePolicyChoice{Newest: &imagev1.NewestPolicy{Source: "created"}},
+			db: &mockDatabase{
+				TagData: []string{"aaa", "bbb", "ccc"},
+				FirstSeenData: map[string]time.Time{
+					"aaa": time.Unix(300, 0),
+				},
+				CreatedData: map[string]time.Time{
+					"aaa": time.Unix(100, 0),
+					"bbb": time.Unix(300, 0),
+				},
+			},
+			wantResult: "bbb",
+		},
+		{
+			name:   "newest policy without times",
+			policy: imagev1.ImagePolicyChoice{Newest: &imagev1.NewestPolicy{Source: "created"}},
+			db: &mockDatabase{
+				TagData: []string{"aaa", "bbb", "ccc"},
+			},
+			wantErr: true,
+		},
+		{
+			name:   "valid tag filter with newest policy",
+			policy: imagev1.ImagePolicyChoice{Newest: &imagev1.NewestPolicy{}},
+			filter: &imagev1.TagFilter{
+				Pattern: "main-(?P<sha>[a-f0-9]+)",
+				Extract: "$sha",
+			},
+			db: &mockDatabase{
+				TagData: []string{"main-abc", "main-def", "dev-fff", "main-aaa"},
+				FirstSeenData: map[string]time.Time{
+					"main-abc": time.Unix(100, 0),
+					"main-def": time.Unix(300, 0),
+					"dev-fff":  time.Unix(400, 0),
+					"main-aaa": time.Unix(200, 0),
+				},
+			},
+			wantResult:     "main-def",
+			wantCandidates: []string{"main-def", "main-aaa", "main-abc"},
+		},
+		{
+			name:   "filter chain with tie-breaker",
+			policy: imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Range: ">=2.0"}},
+			filters: []imagev1.TagFilter{
+				{Pattern: "-rc", Exclude: true},
+				{Pattern: `^(?P<version>[0-9.]+)-(?P<ts>[0-9]+)$`, Extract: "$version"},
+			},
+			tieBreaker: &imagev1.TieBreaker{
+				Pattern: `-(?P<ts>[0-9]+)$`,
+				Extract: "$ts",
+				Policy:  imagev1.ImagePolicyChoice{Numerical: &imagev1.NumericalPolicy{}},
+			},
+			db: &mockDatabase{TagData: []string{
+				"1.0.0-1700000000", "2.0.0-1700000001", "2.0.0-1700000100", "2.0.0-1700000010", "2.1.0-rc.1-1700000200",
+			}},
+			wantResult:     "2.0.0-1700000100",
+			wantCandidates: []string{"2.0.0-1700000100", "2.0.0-1700000010", "2.0.0-1700000001"},
+		},
+		{
+			name:   "semver policy with age constraints",
+			policy: imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Range: ">=1.0.0"}},
+			minAge: &metav1.Duration{Duration: 2 * time.Hour},
+			maxAge: &metav1.Duration{Duration: 30 * 24 * time.Hour},
+			db: &mockDatabase{
+				TagData: []string{"1.0.0", "1.1.0", "1.2.0", "1.3.0"},
+				FirstSeenData: map[string]time.Time{
+					"1.0.0": time.Now().Add(-60 * 24 * time.Hour),
+					"1.1.0": time.Now().Add(-10 * 24 * time.Hour),
+					"1.3.0": time.Now().Add(-time.Hour),
+				},
+			},
+			wantResult:     "1.1.0",
+			wantCandidates: []string{"1.1.0"},
+		},
+		{
+			name:   "no tags old enough",
+			policy: imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Range: ">=1.0.0"}},
+			minAge: &metav1.Duration{Duration: 2 * time.Hour},
+			db: &mockDatabase{
+				TagData:       []string{"1.0.0"},
+				FirstSeenData: map[string]time.Time{"1.0.0": time.Now()},
+			},
+			wantErr: true,
+		},
+		{
+			name:    "minimum age not less than maximum age",
+			policy:  imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Range: ">=1.0.0"}},
+			minAge:  &metav1.Duration{Duration: 2 * time.Hour},
+			maxAge:  &metav1.Duration{Duration: time.Hour},
+			db:      &mockDatabase{TagData: []string{"1.0.0"}},
+			wantErr: true,
+		},
+		{
+			name:   "denied tags and digests",
+			policy: imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Range: ">=1.0.0"}},
+			deny:   []string{"1.3.0", "sha256:2222"},
+			db: &mockDatabase{
+				TagData:    []string{"1.0.0", "1.1.0", "1.2.0", "1.3.0"},
+				DigestData: map[string]string{"1.1.0": "sha256:1111", "1.2.0": "sha256:2222"},
+			},
+			wantResult:     "1.1.0",
+			wantCandidates: []string{"1.1.0", "1.0.0"},
+			wantDecision:   &imagev1.PolicyDecision{Tags: 4, Filtered: 4, Ranked: 4, Value: "1.1.0"},
+		},
+		{
+			name:    "all tags denied",
+			policy:  imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Range: ">=1.0.0"}},
+			deny:    []string{"1.0.0"},
+			db:      &mockDatabase{TagData: []string{"1.0.0"}},
+			wantErr: true,
+		},
+		{
+			name:           "pinned tag",
+			policy:         imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Range: ">=1.0.0"}},
+			pin:            "1.1.0",
+			db:             &mockDatabase{TagData: []string{"1.0.0", "1.1.0", "1.2.0"}},
+			wantResult:     "1.1.0",
+			wantCandidates: []string{"1.2.0", "1.1.0", "1.0.0"},
+			wantPin:        &imagev1.PinStatus{Tag: "1.1.0", PolicyTag: "1.2.0"},
+			wantDecision:   &imagev1.PolicyDecision{Tags: 3, Filtered: 3, Ranked: 3, Value: "1.1.0"},
+		},
+		{
+			name:       "pinned tag with no tag selected by the policy",
+			policy:     imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Range: ">=2.0.0"}},
+			pin:        "1.1.0",
+			db:         &mockDatabase{TagData: []string{"1.0.0", "1.1.0"}},
+			wantResult: "1.1.0",
+			wantPin:    &imagev1.PinStatus{Tag: "1.1.0"},
+		},
+		{
+			name:    "pinned tag not found",
+			policy:  imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Range: ">=1.0.0"}},
+			pin:     "1.3.0",
+			db:      &mockDatabase{TagData: []string{"1.0.0", "1.1.0"}},
+			wantErr: true,
+		},
+		{
+			name:    "pinned tag denied",
+			policy:  imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Range: ">=1.0.0"}},
+			pin:     "1.1.0",
+			deny:    []string{"sha256:1111"},
+			db:      &mockDatabase{TagData: []string{"1.0.0", "1.1.0"}, DigestData: map[string]string{"1.1.0": "sha256:1111"}},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -325,18 +539,405 @@ func TestImagePolicyReconciler_applyPolicy(t *testing.T) {
 			}
 			obj.Spec.Policy = tt.policy
 			obj.Spec.FilterTags = tt.filter
+			obj.Spec.Filters = tt.filters
+			obj.Spec.TieBreaker = tt.tieBreaker
+			obj.Spec.MinAge = tt.minAge
+			obj.Spec.MaxAge = tt.maxAge
+			obj.Spec.Pin = tt.pin
+			obj.Spec.Deny = tt.deny
+
+			repo := &imagev1.ImageRepository{}
+
+			res, err := r.applyPolicy(context.TODO(), obj, repo)
+			g.Expect(err != nil).To(Equal(tt.wantErr))
+			if err == nil {
+				g.Expect(res.latest).To(Equal(tt.wantResult))
+				if tt.wantCandidates != nil {
+					g.Expect(res.candidates).To(Equal(tt.wantCandidates))
+				}
+				g.Expect(res.pin).To(Equal(tt.wantPin))
+				if tt.wantDecision != nil {
+					g.Expect(res.decision).To(Equal(tt.wantDecision))
+				}
+			}
+		})
+	}
+}
+
+func TestFilterByAge(t *testing.T) {
+	now := time.Date(2023, time.June, 1, 12, 0, 0, 0, time.UTC)
+	firstSeen := map[string]time.Time{
+		"old":    now.Add(-48 * time.Hour),
+		"recent": now.Add(-3 * time.Hour),
+		"new":    now.Add(-30 * time.Minute),
+		"newer":  now.Add(-10 * time.Minute),
+		"zero":   {},
+	}
+	tags := []string{"old", "recent", "new", "newer", "zero", "unknown"}
+
+	tests := []struct {
+		name             string
+		minAge           time.Duration
+		maxAge           time.Duration
+		wantTags         []string
+		wantRequeueAfter time.Duration
+	}{
+		{
+			name:     "no constraints",
+			wantTags: tags,
+		},
+		{
+			name:             "minimum age",
+			minAge:           time.Hour,
+			wantTags:         []string{"old", "recent", "zero", "unknown"},
+			wantRequeueAfter: 30 * time.Minute,
+		},
+		{
+			name:             "maximum age",
+			maxAge:           24 * time.Hour,
+			wantTags:         []string{"recent", "new", "newer"},
+			wantRequeueAfter: 21 * time.Hour,
+		},
+		{
+			name:             "minimum and maximum age",
+			minAge:           time.Hour,
+			maxAge:           4 * time.Hour,
+			wantTags:         []string{"recent"},
+			wantRequeueAfter: 30 * time.Minute,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			eligible, requeueAfter := filterByAge(tags, firstSeen, tt.minAge, tt.maxAge, now)
+			g.Expect(eligible).To(Equal(tt.wantTags))
+			g.Expect(requeueAfter).To(Equal(tt.wantRequeueAfter))
+		})
+	}
+}
+
+func TestImagePolicyReconciler_EvaluatePolicy(t *testing.T) {
+	base := time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC)
+	tags := []string{"main-a-100", "main-b-300", "main-c-200", "dev-d-400", "main-e-x"}
+
+	tests := []struct {
+		name    string
+		spec    imagev1.ImagePolicySpec
+		db      *mockDatabase
+		want    *evaluation.Evaluation
+		wantErr bool
+	}{
+		{
+			name: "filter and numerical policy",
+			spec: imagev1.ImagePolicySpec{
+				FilterTags: &imagev1.TagFilter{Pattern: `^main-[a-z]-(?P<ts>.*)$`, Extract: "$ts"},
+				Policy:     imagev1.ImagePolicyChoice{Numerical: &imagev1.NumericalPolicy{SkipInvalid: true}},
+			},
+			db: &mockDatabase{},
+			want: &evaluation.Evaluation{
+				Matched: []string{"main-a-100", "main-b-300", "main-c-200", "main-e-x"},
+				Values:  map[string]string{"main-a-100": "100", "main-b-300": "300", "main-c-200": "200", "main-e-x": "x"},
+				Ranked:  []string{"main-b-300", "main-c-200", "main-a-100"},
+				Latest:  "main-b-300",
+				Skipped: 1,
+			},
+		},
+		{
+			name: "newest policy",
+			spec: imagev1.ImagePolicySpec{
+				FilterTags: &imagev1.TagFilter{Pattern: `^(main-[ab]|dev)-`},
+				Policy:     imagev1.ImagePolicyChoice{Newest: &imagev1.NewestPolicy{}},
+			},
+			db: &mockDatabase{FirstSeenData: map[string]time.Time{
+				"dev-d-400":  base,
+				"main-a-100": base.Add(time.Hour),
+				"main-b-300": base.Add(-time.Hour),
+			}},
+			want: &evaluation.Evaluation{
+				Matched: []string{"dev-d-400", "main-a-100", "main-b-300"},
+				Values:  map[string]string{"dev-d-400": "dev-d-400", "main-a-100": "main-a-100", "main-b-300": "main-b-300"},
+				Ranked:  []string{"main-a-100", "dev-d-400", "main-b-300"},
+				Latest:  "main-a-100",
+			},
+		},
+		{
+			name: "age constraints and deny list not evaluated",
+			spec: imagev1.ImagePolicySpec{
+				Policy: imagev1.ImagePolicyChoice{Alphabetical: &imagev1.AlphabeticalPolicy{}},
+				MinAge: &metav1.Duration{Duration: time.Hour},
+				Deny:   []string{"main-e-x"},
+			},
+			db: &mockDatabase{FirstSeenData: map[string]time.Time{"main-e-x": time.Now()}},
+			want: &evaluation.Evaluation{
+				Matched: []string{"dev-d-400", "main-a-100", "main-b-300", "main-c-200", "main-e-x"},
+				Values:  map[string]string{"dev-d-400": "dev-d-400", "main-a-100": "main-a-100", "main-b-300": "main-b-300", "main-c-200": "main-c-200", "main-e-x": "main-e-x"},
+				Ranked:  []string{"main-e-x", "main-c-200", "main-b-300", "main-a-100", "dev-d-400"},
+				Latest:  "main-e-x",
+			},
+		},
+		{
+			name: "no tag selected",
+			spec: imagev1.ImagePolicySpec{
+				Policy: imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Range: ">=1.0.0"}},
+			},
+			db: &mockDatabase{},
+			want: &evaluation.Evaluation{
+				Matched: []string{"dev-d-400", "main-a-100", "main-b-300", "main-c-200", "main-e-x"},
+				Values:  map[string]string{"dev-d-400": "dev-d-400", "main-a-100": "main-a-100", "main-b-300": "main-b-300", "main-c-200": "main-c-200", "main-e-x": "main-e-x"},
+				Ranked:  []string{},
+				Skipped: 5,
+				Error:   "unable to determine latest version from provided list",
+			},
+		},
+		{
+			name: "invalid spec",
+			spec: imagev1.ImagePolicySpec{
+				FilterTags: &imagev1.TagFilter{Pattern: `(`},
+				Policy:     imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Range: ">=1.0.0"}},
+			},
+			db:      &mockDatabase{},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			r := &ImagePolicyReconciler{
+				Database: tt.db,
+			}
+			e, err := r.EvaluatePolicy(tt.spec, "ghcr.io/org/app", tags)
+			g.Expect(err != nil).To(Equal(tt.wantErr))
+			g.Expect(e).To(Equal(tt.want))
+		})
+	}
+}
+
+// registryOptions gives fixed options to access registries.
+type registryOptions []remote.Option
+
+// RegistryOptions implements RegistryOptionsGetter.
+func (o registryOptions) RegistryOptions(context.Context, *imagev1.ImageRepository) ([]remote.Option, error) {
+	return o, nil
+}
+
+func TestImagePolicyReconciler_applyPolicyVerify(t *testing.T) {
+	g := NewWithT(t)
+
+	registryServer := test.NewRegistryServer()
+	defer registryServer.Close()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	g.Expect(err).ToNot(HaveOccurred())
+	pub, err := test.PublicKeyPEM(key)
+	g.Expect(err).ToNot(HaveOccurred())
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	// 1.0.0 and 1.1.0 are signed with the trusted key, 1.2.0 with another
+	// key and 2.0.0 is not signed.
+	imgRepo, err := test.LoadImages(registryServer, "verify", []string{"1.0.0", "1.1.0", "1.2.0", "2.0.0"})
+	g.Expect(err).ToNot(HaveOccurred())
+	digests := map[string]string{}
+	for _, tag := range []string{"1.0.0", "1.1.0", "1.2.0", "2.0.0"} {
+		ref, err := name.NewTag(imgRepo + ":" + tag)
+		g.Expect(err).ToNot(HaveOccurred())
+		desc, err := remote.Head(ref)
+		g.Expect(err).ToNot(HaveOccurred())
+		digests[tag] = desc.Digest.String()
+	}
+	sign := func(tag string, key *ecdsa.PrivateKey) {
+		digest, err := name.NewDigest(imgRepo + "@" + digests[tag])
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(test.SignCosign(digest, key)).To(Succeed())
+	}
+	sign("1.0.0", key)
+	sign("1.1.0", key)
+	sign("1.2.0", otherKey)
+
+	keySecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "cosign-keys", Namespace: "default"},
+		Data:       map[string][]byte{"cosign.pub": pub},
+	}
+
+	tests := []struct {
+		name           string
+		semver         string
+		verify         *imagev1.ImageVerification
+		reflectDigests bool
+		digests        map[string]string
+		keylessRoot    *verify.TrustedRoot
+		wantResult     string
+		wantErr        bool
+		wantErrType    error
+	}{
+		{
+			name:       "highest ranked tag verified",
+			semver:     "1.1.x",
+			verify:     &imagev1.ImageVerification{SecretRef: &meta.LocalObjectReference{Name: "cosign-keys"}},
+			wantResult: "1.1.0",
+		},
+		{
+			name:       "next candidates verified",
+			semver:     ">=1.0.0",
+			verify:     &imagev1.ImageVerification{SecretRef: &meta.LocalObjectReference{Name: "cosign-keys"}},
+			wantResult: "1.1.0",
+		},
+		{
+			name:        "no candidate verified",
+			semver:      ">=1.2.0",
+			verify:      &imagev1.ImageVerification{SecretRef: &meta.LocalObjectReference{Name: "cosign-keys"}},
+			wantErr:     true,
+			wantErrType: errVerificationFailed{},
+		},
+		{
+			name:           "pinned digest verified",
+			semver:         ">=1.0.0",
+			verify:         &imagev1.ImageVerification{SecretRef: &meta.LocalObjectReference{Name: "cosign-keys"}},
+			reflectDigests: true,
+			// 2.0.0 pointed at the signed image of 1.0.0 when scanned.
+			digests:    map[string]string{"2.0.0": digests["1.0.0"]},
+			wantResult: "2.0.0",
+		},
+		{
+			name:    "missing secret",
+			semver:  ">=1.0.0",
+			verify:  &imagev1.ImageVerification{SecretRef: &meta.LocalObjectReference{Name: "missing"}},
+			wantErr: true,
+		},
+		{
+			name:   "keyless not configured",
+			semver: ">=1.0.0",
+			verify: &imagev1.ImageVerification{
+				MatchOIDCIdentity: []imagev1.OIDCIdentityMatch{{Issuer: ".*", Subject: ".*"}},
+			},
+			wantErr: true,
+		},
+		{
+			name:        "keyless without identities",
+			semver:      ">=1.0.0",
+			verify:      &imagev1.ImageVerification{},
+			keylessRoot: &verify.TrustedRoot{},
+			wantErr:     true,
+			wantErrType: errInvalidPolicy{},
+		},
+		{
+			name:   "invalid identity pattern",
+			semver: ">=1.0.0",
+			verify: &imagev1.ImageVerification{
+				MatchOIDCIdentity: []imagev1.OIDCIdentityMatch{{Issuer: "(", Subject: ".*"}},
+			},
+			keylessRoot: &verify.TrustedRoot{},
+			wantErr:     true,
+			wantErrType: errInvalidPolicy{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			c := fake.NewClientBuilder().WithObjects(keySecret).Build()
+			r := &ImagePolicyReconciler{
+				Client:             c,
+				EventRecorder:      record.NewFakeRecorder(32),
+				Database:           &mockDatabase{TagData: []string{"1.0.0", "1.1.0", "1.2.0", "2.0.0"}, DigestData: tt.digests},
+				RegistryOptions:    registryOptions{},
+				KeylessTrustedRoot: tt.keylessRoot,
+			}
+
+			obj := &imagev1.ImagePolicy{
+				ObjectMeta: metav1.ObjectMeta{Name: "verify", Namespace: "default"},
+			}
+			obj.Spec.Policy = imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Range: tt.semver}}
+			obj.Spec.Verify = tt.verify
+
+			repo := &imagev1.ImageRepository{}
+			repo.Spec.Image = imgRepo
+			repo.Spec.ReflectDigests = tt.reflectDigests
+
+			res, err := r.applyPolicy(context.TODO(), obj, repo)
+			g.Expect(err != nil).To(Equal(tt.wantErr), "%v", err)
+			if tt.wantErrType != nil {
+				g.Expect(err).To(BeAssignableToTypeOf(tt.wantErrType))
+			}
+			if err == nil {
+				g.Expect(res.latest).To(Equal(tt.wantResult))
+			}
+		})
+	}
+}
+
+func TestImagePolicyReconciler_pinImage(t *testing.T) {
+	tests := []struct {
+		name           string
+		reflectDigests bool
+		tag            string
+		db             *mockDatabase
+		wantErr        bool
+		wantImage      string
+	}{
+		{
+			name:      "digests not reflected",
+			tag:       "1.0.0",
+			db:        &mockDatabase{DigestData: map[string]string{"1.0.0": "sha256:aaaa"}},
+			wantImage: "foo/bar:1.0.0",
+		},
+		{
+			name:           "digests reflected",
+			reflectDigests: true,
+			tag:            "1.0.0",
+			db:             &mockDatabase{DigestData: map[string]string{"1.0.0": "sha256:aaaa"}},
+			wantImage:      "foo/bar:1.0.0@sha256:aaaa",
+		},
+		{
+			name:           "digests reflected, no digest for tag",
+			reflectDigests: true,
+			tag:            "1.0.1",
+			db:             &mockDatabase{DigestData: map[string]string{"1.0.0": "sha256:aaaa"}},
+			wantImage:      "foo/bar:1.0.1",
+		},
+		{
+			name:           "database read fail",
+			reflectDigests: true,
+			tag:            "1.0.0",
+			db:             &mockDatabase{ReadError: errors.New("fail")},
+			wantErr:        true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			r := &ImagePolicyReconciler{
+				EventRecorder: record.NewFakeRecorder(32),
+				Database:      tt.db,
+			}
 
 			repo := &imagev1.ImageRepository{}
+			repo.Spec.Image = "foo/bar"
+			repo.Spec.ReflectDigests = tt.reflectDigests
 
-			result, err := r.applyPolicy(context.TODO(), obj, repo)
+			result, err := r.pinImage(repo, tt.tag)
 			g.Expect(err != nil).To(Equal(tt.wantErr))
 			if err == nil {
-				g.Expect(result).To(Equal(tt.wantResult))
+				g.Expect(result).To(Equal(tt.wantImage))
 			}
 		})
 	}
 }
 
+func TestTrimDigest(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(trimDigest("foo/bar:1.0.0")).To(Equal("foo/bar:1.0.0"))
+	g.Expect(trimDigest("localhost:5000/foo/bar:1.0.0@sha256:aaaa")).To(Equal("localhost:5000/foo/bar:1.0.0"))
+}
+
 func TestComposeImagePolicyReadyMessage(t *testing.T) {
 	testImage := "foo/bar"
 
@@ -18,17 +18,23 @@ package controller
 
 import (
 	"context"
+	"crypto/sha256"
 	"errors"
 	"fmt"
+	"net/http"
 	"regexp"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/authn/k8schain"
 	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"golang.org/x/sync/errgroup"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -40,8 +46,11 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/ratelimiter"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	eventv1 "github.com/fluxcd/pkg/apis/event/v1beta1"
 	"github.com/fluxcd/pkg/apis/meta"
@@ -54,12 +63,25 @@ import (
 	"github.com/fluxcd/pkg/runtime/reconcile"
 
 	imagev1 "github.com/fluxcd/image-reflector-controller/api/v1beta2"
+	"github.com/fluxcd/image-reflector-controller/internal/ratelimit"
 	"github.com/fluxcd/image-reflector-controller/internal/secret"
 )
 
 // latestTagsCount is the number of tags to use as latest tags.
 const latestTagsCount = 10
 
+// canonicalImageNameKey is the key used for indexing the image repositories
+// by their canonical image name.
+const canonicalImageNameKey = ".status.canonicalImageName"
+
+// scanEventsBuffer is the number of scan requests that can be queued for the
+// controller before RequestScan blocks.
+const scanEventsBuffer = 100
+
+// digestWorkers is the number of concurrent requests made to the registry
+// when resolving the digests of the tags of a repository.
+const digestWorkers = 4
+
 // imageRepositoryOwnedConditions is a list of conditions owned by the
 // ImageRepositoryReconciler.
 var imageRepositoryOwnedConditions = []string{
@@ -82,7 +104,12 @@ const (
 	scanReasonReconcileRequested   = "reconcile requested"
 	scanReasonNewImageName         = "new image name"
 	scanReasonUpdatedExclusionList = "updated exclusion list"
+	scanReasonUpdatedInclusionList = "updated inclusion list"
+	scanReasonUpdatedPlatforms     = "updated platforms"
 	scanReasonEmptyDatabase        = "no tags in database"
+	scanReasonNoDigests            = "no digests in database"
+	scanReasonInterruptedScan      = "resuming interrupted scan"
+	scanReasonScanRequested        = "scan requested by push notification"
 	scanReasonInterval             = "triggered by interval"
 )
 
@@ -113,8 +140,13 @@ type ImageRepositoryReconciler struct {
 		DatabaseReader
 	}
 	DeprecatedLoginOpts login.ProviderOptions
+	// RegistryLimiter, if set, limits the rate of the requests made to the
+	// registries by the scans.
+	RegistryLimiter *ratelimit.Limiter
 
 	patchOptions []patch.Option
+	scanRequests scanRequests
+	scanEvents   chan event.GenericEvent
 }
 
 type ImageRepositoryReconcilerOptions struct {
@@ -123,9 +155,17 @@ type ImageRepositoryReconcilerOptions struct {
 
 func (r *ImageRepositoryReconciler) SetupWithManager(mgr ctrl.Manager, opts ImageRepositoryReconcilerOptions) error {
 	r.patchOptions = getPatchOptions(imageRepositoryOwnedConditions, r.ControllerName)
+	r.scanEvents = make(chan event.GenericEvent, scanEventsBuffer)
+
+	// index the repositories by their canonical image name, so that it's easy
+	// to list those out when a push notification is received.
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &imagev1.ImageRepository{}, canonicalImageNameKey, indexCanonicalImageName); err != nil {
+		return err
+	}
 
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&imagev1.ImageRepository{}).
+		WatchesRawSource(&source.Channel{Source: r.scanEvents}, &handler.EnqueueRequestForObject{}).
 		WithEventFilter(predicate.Or(predicate.GenerationChangedPredicate{}, predicates.ReconcileRequestedPredicate{})).
 		WithOptions(controller.Options{
 			RateLimiter: opts.RateLimiter,
@@ -283,14 +323,32 @@ func (r *ImageRepositoryReconciler) reconcile(ctx context.Context, sp *patch.Ser
 			return
 		}
 
+		// Scan requests received during the scan are kept for the next one.
+		key := client.ObjectKeyFromObject(obj)
+		seq, _ := r.scanRequests.get(key)
+
 		tags, err := r.scan(ctx, obj, ref, opts)
 		if err != nil {
+			// Retry throttled scans once the rate limit allows, without
+			// counting them as failures. A zero RequeueAfter would retry
+			// them straight away.
+			var throttled *ratelimit.ThrottledError
+			if errors.As(err, &throttled) {
+				conditions.MarkFalse(obj, meta.ReadyCondition, imagev1.RateLimitedReason, "scan throttled: %s", throttled.Error())
+				requeueAfter := throttled.RetryAfter
+				if requeueAfter < ratelimit.MinBackoff {
+					requeueAfter = ratelimit.MinBackoff
+				}
+				result, retErr = ctrl.Result{RequeueAfter: requeueAfter}, nil
+				return
+			}
 			e := fmt.Errorf("scan failed: %w", err)
 			conditions.MarkFalse(obj, meta.ReadyCondition, imagev1.ReadOperationFailedReason, e.Error())
 			result, retErr = ctrl.Result{}, e
 			return
 		}
 		foundTags = tags
+		r.scanRequests.done(key, seq)
 
 		nextScanMsg = fmt.Sprintf("next scan in %s", when.String())
 		// Check if new tags were found.
@@ -311,6 +369,8 @@ func (r *ImageRepositoryReconciler) reconcile(ctx context.Context, sp *patch.Ser
 	// Set the observations on the status.
 	obj.Status.CanonicalImageName = ref.Context().String()
 	obj.Status.ObservedExclusionList = obj.GetExclusionList()
+	obj.Status.ObservedInclusionList = obj.Spec.InclusionList
+	obj.Status.ObservedPlatforms = obj.Spec.Platforms
 
 	// Remove any stale Ready condition, most likely False, set above. Its value
 	// is derived from the overall result of the reconciliation in the deferred
@@ -323,6 +383,91 @@ func (r *ImageRepositoryReconciler) reconcile(ctx context.Context, sp *patch.Ser
 	return
 }
 
+// RequestScan requests an immediate scan of the ImageRepositories with the
+// given canonical image name, e.g. on a push notification from the registry. It
+// returns the ImageRepositories the scan was requested for. Suspended
+// ImageRepositories are skipped.
+func (r *ImageRepositoryReconciler) RequestScan(ctx context.Context, canonicalName string) ([]types.NamespacedName, error) {
+	var list imagev1.ImageRepositoryList
+	if err := r.List(ctx, &list, client.MatchingFields{canonicalImageNameKey: canonicalName}); err != nil {
+		return nil, err
+	}
+
+	var requested []types.NamespacedName
+	for i := range list.Items {
+		obj := &list.Items[i]
+		if obj.Spec.Suspend {
+			continue
+		}
+		key := client.ObjectKeyFromObject(obj)
+		r.scanRequests.add(key)
+		select {
+		case r.scanEvents <- event.GenericEvent{Object: obj}:
+		case <-ctx.Done():
+			return requested, ctx.Err()
+		}
+		requested = append(requested, key)
+	}
+	return requested, nil
+}
+
+// indexCanonicalImageName is the field indexer function for the canonical image
+// name of the ImageRepositories.
+func indexCanonicalImageName(obj client.Object) []string {
+	repo := obj.(*imagev1.ImageRepository)
+	if repo.Status.CanonicalImageName == "" {
+		return nil
+	}
+	return []string{repo.Status.CanonicalImageName}
+}
+
+// scanRequests records the ImageRepositories requested to be scanned until
+// they're scanned. Every request increments a sequence number, so that the
+// requests received while a scan is in progress aren't marked done by it.
+type scanRequests struct {
+	mu  sync.Mutex
+	seq map[types.NamespacedName]uint64
+}
+
+// add records a scan request for the ImageRepository.
+func (s *scanRequests) add(key types.NamespacedName) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.seq == nil {
+		s.seq = map[types.NamespacedName]uint64{}
+	}
+	s.seq[key]++
+}
+
+// get returns the sequence number of the last scan request recorded for the
+// ImageRepository, and whether there's one.
+func (s *scanRequests) get(key types.NamespacedName) (uint64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	seq, ok := s.seq[key]
+	return seq, ok
+}
+
+// done removes the scan request of the ImageRepository if no other request has
+// been recorded since the one with the given sequence number.
+func (s *scanRequests) done(key types.NamespacedName, seq uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.seq[key] == seq {
+		delete(s.seq, key)
+	}
+}
+
+// RegistryOptions returns the options required to access the registry of the
+// given ImageRepository, e.g. to verify the signatures of its images.
+func (r *ImageRepositoryReconciler) RegistryOptions(ctx context.Context, obj *imagev1.ImageRepository) ([]remote.Option, error) {
+	ref, err := parseImageReference(obj.Spec.Image)
+	if err != nil {
+		return nil, err
+	}
+	return r.setAuthOptions(ctx, obj, ref)
+}
+
 // setAuthOptions returns authentication options required to scan a repository.
 func (r *ImageRepositoryReconciler) setAuthOptions(ctx context.Context, obj *imagev1.ImageRepository, ref name.Reference) ([]remote.Option, error) {
 	timeout := obj.GetTimeout()
@@ -371,6 +516,7 @@ func (r *ImageRepositoryReconciler) setAuthOptions(ctx context.Context, obj *ima
 	}
 
 	// Load any provided certificate.
+	var tr http.RoundTripper
 	if obj.Spec.CertSecretRef != nil {
 		var certSecret corev1.Secret
 		if obj.Spec.SecretRef != nil && obj.Spec.SecretRef.Name == obj.Spec.CertSecretRef.Name {
@@ -384,13 +530,28 @@ func (r *ImageRepositoryReconciler) setAuthOptions(ctx context.Context, obj *ima
 			}
 		}
 
-		tr, err := secret.TransportFromSecret(&certSecret)
+		t, err := secret.TransportFromSecret(&certSecret)
 		if err != nil {
 			return nil, err
 		}
-		options = append(options, remote.WithTransport(tr))
+		tr = t
 	}
 
+	// Requests are rate limited per registry through the transport.
+	if r.RegistryLimiter != nil {
+		if tr == nil {
+			tr = remote.DefaultTransport
+		}
+		tr = r.RegistryLimiter.Transport(tr)
+	}
+
+	// Tag list requests are made conditional, or resumed for paginated scans,
+	// through the transport.
+	if tr == nil {
+		tr = remote.DefaultTransport
+	}
+	options = append(options, remote.WithTransport(&tagListTransport{base: tr}))
+
 	if obj.Spec.ServiceAccountName != "" {
 		serviceAccount := corev1.ServiceAccount{}
 		// Lookup service account
@@ -433,6 +594,7 @@ func (r *ImageRepositoryReconciler) setAuthOptions(ctx context.Context, obj *ima
 //   - the image URL has changed
 //   - the exclusion list has changed
 //   - there's no tag in the database
+//   - digests are to be reflected but there's no digest in the database
 //   - the difference between current time and last time is more than the scan
 //     interval
 //
@@ -456,6 +618,12 @@ func (r *ImageRepositoryReconciler) shouldScan(obj imagev1.ImageRepository, now
 		}
 	}
 
+	// Has a scan been requested, e.g. by a push notification from the
+	// registry?
+	if _, ok := r.scanRequests.get(client.ObjectKeyFromObject(&obj)); ok {
+		return true, scanInterval, scanReasonScanRequested, nil
+	}
+
 	// If the canonical image name of the image is different from the last
 	// observed name, scan now.
 	ref, err := parseImageReference(obj.Spec.Image)
@@ -471,6 +639,27 @@ func (r *ImageRepositoryReconciler) shouldScan(obj imagev1.ImageRepository, now
 		return true, scanInterval, scanReasonUpdatedExclusionList, nil
 	}
 
+	// If the inclusion list has changed, scan now.
+	if !isEqualSliceContent(obj.Spec.InclusionList, obj.Status.ObservedInclusionList) {
+		return true, scanInterval, scanReasonUpdatedInclusionList, nil
+	}
+
+	// If the platforms have changed, scan now.
+	if !isEqualSliceContent(obj.Spec.Platforms, obj.Status.ObservedPlatforms) {
+		return true, scanInterval, scanReasonUpdatedPlatforms, nil
+	}
+
+	// If a paginated scan was interrupted, resume it now.
+	if obj.Spec.ScanPageSize > 0 {
+		last, err := r.Database.LastPendingTag(obj.Status.CanonicalImageName)
+		if err != nil {
+			return false, scanInterval, "", err
+		}
+		if last != "" {
+			return true, scanInterval, scanReasonInterruptedScan, nil
+		}
+	}
+
 	// when recovering, it's possible that the resource has a last
 	// scan time, but there's no records because the database has been
 	// dropped and created again.
@@ -486,6 +675,18 @@ func (r *ImageRepositoryReconciler) shouldScan(obj imagev1.ImageRepository, now
 		return true, scanInterval, scanReasonEmptyDatabase, nil
 	}
 
+	// If the digests are to be reflected and there are none, most likely
+	// because reflecting digests has just been enabled, scan now.
+	if obj.Spec.ReflectDigests {
+		digests, err := r.Database.Digests(obj.Status.CanonicalImageName)
+		if err != nil {
+			return false, scanInterval, "", err
+		}
+		if len(digests) == 0 {
+			return true, scanInterval, scanReasonNoDigests, nil
+		}
+	}
+
 	when := scanInterval - now.Sub(lastScanTime.Time)
 	if when < time.Second {
 		return true, scanInterval, scanReasonInterval, nil
@@ -502,26 +703,173 @@ func (r *ImageRepositoryReconciler) scan(ctx context.Context, obj *imagev1.Image
 
 	options = append(options, remote.WithContext(ctx))
 
-	tags, err := remote.List(ref.Context(), options...)
+	canonicalName := ref.Context().String()
+	scanTime := metav1.Now()
+
+	platforms, err := parsePlatforms(obj.Spec.Platforms)
 	if err != nil {
 		return 0, err
 	}
 
-	filteredTags, err := filterOutTags(tags, obj.GetExclusionList())
+	// Request the tags on the condition that they changed since the previous
+	// scan, unless the recorded tags were filtered with other inclusion or
+	// exclusion lists. Tags filtered by platform are always listed, as the
+	// images they point at may have changed.
+	etag, previousTagsDigest, err := r.Database.TagsValidator(canonicalName)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read tags validator for %q: %w", canonicalName, err)
+	}
+	if len(platforms) > 0 || canonicalName != obj.Status.CanonicalImageName ||
+		!isEqualSliceContent(obj.GetExclusionList(), obj.Status.ObservedExclusionList) ||
+		!isEqualSliceContent(obj.Spec.InclusionList, obj.Status.ObservedInclusionList) {
+		etag = ""
+	}
+
+	list, err := r.listTags(ctx, obj, ref.Context(), options, etag)
 	if err != nil {
 		return 0, err
 	}
 
-	canonicalName := ref.Context().String()
-	if err := r.Database.SetTags(canonicalName, filteredTags); err != nil {
-		return 0, fmt.Errorf("failed to set tags for %q: %w", canonicalName, err)
+	var filteredTags []string
+	if list.notModified {
+		filteredTags, err = r.Database.Tags(canonicalName)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read tags for %q: %w", canonicalName, err)
+		}
+	} else {
+		filteredTags, err = filterInTags(list.tags, obj.Spec.InclusionList)
+		if err != nil {
+			return 0, err
+		}
+		filteredTags, err = filterOutTags(filteredTags, obj.GetExclusionList())
+		if err != nil {
+			return 0, err
+		}
+	}
+	// Resolve the digests of the tags if they are reflected or needed to
+	// filter the tags by platform.
+	var resolvedDigests map[string]string
+	if obj.Spec.ReflectDigests || len(platforms) > 0 {
+		resolvedDigests, err = resolveDigests(ctx, ref.Context(), filteredTags, options)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	// Keep only the tags available for all the platforms. The platforms of
+	// the digests are recorded, and only resolved for new digests. An empty
+	// set is recorded otherwise, to not leave stale platforms behind.
+	digestPlatforms := map[string][]string{}
+	if len(platforms) > 0 {
+		previousPlatforms, err := r.Database.Platforms(canonicalName)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read platforms for %q: %w", canonicalName, err)
+		}
+		digestPlatforms, err = resolvePlatforms(ctx, ref.Context(), resolvedDigests, previousPlatforms, options)
+		if err != nil {
+			return 0, err
+		}
+		filteredTags = filterPlatformTags(filteredTags, resolvedDigests, digestPlatforms, platforms)
+	}
+
+	tagsDigest := digestTags(filteredTags)
+	unchanged := tagsDigest == previousTagsDigest
+
+	// Compare the digests of the tags with the digests recorded by the
+	// previous scan if enabled. The creation times of the images are resolved
+	// along, reusing the times recorded for the digests that didn't change.
+	// Empty sets are recorded otherwise, to not leave stale digests and times
+	// behind.
+	digests := map[string]string{}
+	created := map[string]time.Time{}
+	var mutations []imagev1.TagMutation
+	if obj.Spec.ReflectDigests {
+		for _, tag := range filteredTags {
+			if digest, ok := resolvedDigests[tag]; ok {
+				digests[tag] = digest
+			}
+		}
+		previousDigests, err := r.Database.Digests(canonicalName)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read digests for %q: %w", canonicalName, err)
+		}
+		mutations = findTagMutations(previousDigests, digests)
+
+		previousCreated, err := r.Database.Created(canonicalName)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read creation times for %q: %w", canonicalName, err)
+		}
+		created, err = resolveCreated(ctx, ref.Context(), digests, previousDigests, previousCreated, options)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	// Record the time the tags were first seen, keeping the times of the tags
+	// seen by the previous scans. On the first scan of the repository, the
+	// tags predate the scan: they are recorded with the creation time of their
+	// image if known, or the zero time meaning unknown, for the minimum age of
+	// the policies not to hold them back as new tags. Once the repository was
+	// scanned, even without finding tags, the new tags are first seen now.
+	previousFirstSeen, err := r.Database.FirstSeen(canonicalName)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read first seen times for %q: %w", canonicalName, err)
+	}
+	initialScan := len(previousFirstSeen) == 0 && obj.Status.LastScanResult == nil
+	firstSeen := make(map[string]time.Time, len(filteredTags))
+	for _, tag := range filteredTags {
+		if t, ok := previousFirstSeen[tag]; ok {
+			firstSeen[tag] = t
+			continue
+		}
+		if initialScan {
+			firstSeen[tag] = created[tag]
+			continue
+		}
+		firstSeen[tag] = scanTime.Time
+	}
+
+	// The tags and the times they were first seen are only rewritten if the
+	// tags changed.
+	if !unchanged {
+		if err := r.Database.SetTags(canonicalName, filteredTags); err != nil {
+			return 0, fmt.Errorf("failed to set tags for %q: %w", canonicalName, err)
+		}
+		if err := r.Database.SetFirstSeen(canonicalName, firstSeen); err != nil {
+			return 0, fmt.Errorf("failed to set first seen times for %q: %w", canonicalName, err)
+		}
+	}
+	if err := r.Database.SetTagsValidator(canonicalName, list.etag, tagsDigest); err != nil {
+		return 0, fmt.Errorf("failed to set tags validator for %q: %w", canonicalName, err)
+	}
+	if err := r.Database.SetDigests(canonicalName, digests); err != nil {
+		return 0, fmt.Errorf("failed to set digests for %q: %w", canonicalName, err)
+	}
+	if err := r.Database.SetCreated(canonicalName, created); err != nil {
+		return 0, fmt.Errorf("failed to set creation times for %q: %w", canonicalName, err)
+	}
+	if err := r.Database.SetPlatforms(canonicalName, digestPlatforms); err != nil {
+		return 0, fmt.Errorf("failed to set platforms for %q: %w", canonicalName, err)
+	}
+	if err := r.Database.ClearPendingTags(canonicalName); err != nil {
+		return 0, fmt.Errorf("failed to clear pending tags for %q: %w", canonicalName, err)
 	}
 
-	scanTime := metav1.Now()
 	obj.Status.LastScanResult = &imagev1.ScanResult{
 		TagCount:   len(filteredTags),
 		ScanTime:   scanTime,
 		LatestTags: getLatestTags(filteredTags),
+		Unchanged:  unchanged,
+	}
+
+	obj.Status.MutatedTags = mutations
+	if len(mutations) > 0 {
+		mutated := make([]string, len(mutations))
+		for i, m := range mutations {
+			mutated[i] = fmt.Sprintf("%s (%s -> %s)", m.Tag, m.PreviousDigest, m.Digest)
+		}
+		eventLogf(ctx, r.EventRecorder, obj, corev1.EventTypeWarning, imagev1.TagMutatedReason,
+			"tags changed digest since the previous scan: %s", strings.Join(mutated, ", "))
 	}
 
 	// If the reconcile request annotation was set, consider it
@@ -534,8 +882,445 @@ func (r *ImageRepositoryReconciler) scan(ctx context.Context, obj *imagev1.Image
 	return len(filteredTags), nil
 }
 
+// tagList is the result of listing the tags of a repository.
+type tagList struct {
+	tags []string
+	// etag is the ETag of the tag list response, if the registry returned all
+	// the tags in a single response.
+	etag string
+	// notModified is true if the registry responded that the tags didn't
+	// change since the response with the ETag given in the request.
+	notModified bool
+}
+
+// listTags lists the tags of the repository. If an ETag is given, the tags are
+// requested on the condition that they changed since the response with the
+// ETag.
+//
+// If the ImageRepository specifies a scan page size, the tags are instead
+// listed page by page, appending every page to the pending tags of the
+// repository in the database. Listing resumes after the last pending tag, left
+// behind by a scan that didn't complete.
+func (r *ImageRepositoryReconciler) listTags(ctx context.Context, obj *imagev1.ImageRepository, repo name.Repository, options []remote.Option, etag string) (tagList, error) {
+	if obj.Spec.ScanPageSize <= 0 {
+		req := &tagListRequest{ifNoneMatch: etag}
+		opts := append([]remote.Option{}, options...)
+		opts = append(opts, remote.WithContext(context.WithValue(ctx, tagListRequestKey{}, req)))
+		tags, err := remote.List(repo, opts...)
+		if errors.Is(err, errTagListNotModified) {
+			return tagList{etag: etag, notModified: true}, nil
+		}
+		if err != nil {
+			return tagList{}, err
+		}
+		return tagList{tags: tags, etag: req.etag}, nil
+	}
+
+	canonicalName := repo.String()
+	last, err := r.Database.LastPendingTag(canonicalName)
+	if err != nil {
+		return tagList{}, fmt.Errorf("failed to read pending tags for %q: %w", canonicalName, err)
+	}
+	// Only the request for the first page resumes after the last pending tag,
+	// the following pages are requested as given by the registry.
+	firstCtx := ctx
+	if last != "" {
+		firstCtx = context.WithValue(ctx, tagListRequestKey{}, &tagListRequest{last: last})
+	}
+
+	opts := append([]remote.Option{}, options...)
+	opts = append(opts, remote.WithPageSize(obj.Spec.ScanPageSize))
+	puller, err := remote.NewPuller(opts...)
+	if err != nil {
+		return tagList{}, err
+	}
+	lister, err := puller.Lister(firstCtx, repo)
+	if err != nil {
+		return tagList{}, err
+	}
+	for lister.HasNext() {
+		page, err := lister.Next(ctx)
+		if err != nil {
+			return tagList{}, err
+		}
+		if len(page.Tags) == 0 {
+			continue
+		}
+		if err := r.Database.AppendPendingTags(canonicalName, page.Tags); err != nil {
+			return tagList{}, fmt.Errorf("failed to append pending tags for %q: %w", canonicalName, err)
+		}
+	}
+	// The pages listed by all the attempts are only read once they're all
+	// listed.
+	tags, err := r.Database.PendingTags(canonicalName)
+	if err != nil {
+		return tagList{}, fmt.Errorf("failed to read pending tags for %q: %w", canonicalName, err)
+	}
+	return tagList{tags: tags}, nil
+}
+
+// errTagListNotModified is returned for conditional tag list requests when the
+// registry responded that the tags didn't change.
+var errTagListNotModified = errors.New("tag list not modified")
+
+// tagListRequestKey is the context key of the tagListRequest of the tag list
+// requests.
+type tagListRequestKey struct{}
+
+// tagListRequest tweaks the first tag list request made with a context carrying
+// it, and records the ETag of the response.
+type tagListRequest struct {
+	// last is the tag after which the registry lists the tags.
+	last string
+	// ifNoneMatch is the ETag of a previous response, making the request
+	// conditional.
+	ifNoneMatch string
+	// etag is the ETag of the response, if the registry returned all the tags
+	// in a single response.
+	etag string
+}
+
+// tagListTransport is a transport applying the tagListRequest carried by the
+// context of the first tag list request, i.e. the one without the `last` query
+// parameter. The requests for the following pages are left as given by the
+// registry in the Link header.
+type tagListTransport struct {
+	base http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *tagListTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	lr, ok := req.Context().Value(tagListRequestKey{}).(*tagListRequest)
+	if !ok || req.Method != http.MethodGet || !strings.HasSuffix(req.URL.Path, "/tags/list") {
+		return t.base.RoundTrip(req)
+	}
+	query := req.URL.Query()
+	if query.Has("last") {
+		return t.base.RoundTrip(req)
+	}
+
+	req = req.Clone(req.Context())
+	if lr.last != "" {
+		query.Set("last", lr.last)
+		req.URL.RawQuery = query.Encode()
+	}
+	if lr.ifNoneMatch != "" {
+		req.Header.Set("If-None-Match", lr.ifNoneMatch)
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotModified && lr.ifNoneMatch != "":
+		resp.Body.Close()
+		return nil, errTagListNotModified
+	case resp.StatusCode == http.StatusOK && resp.Header.Get("Link") == "":
+		lr.etag = resp.Header.Get("ETag")
+	}
+	return resp, nil
+}
+
+// digestTags returns a digest of the given list of tags.
+func digestTags(tags []string) string {
+	h := sha256.New()
+	for _, tag := range tags {
+		h.Write([]byte(tag))
+		h.Write([]byte{'\n'})
+	}
+	return fmt.Sprintf("sha256:%x", h.Sum(nil))
+}
+
+// resolveDigests fetches the manifest digest of each of the given tags of the
+// repository and returns them keyed by tag. Tags that no longer exist in the
+// registry, e.g. deleted since they were listed, are skipped.
+func resolveDigests(ctx context.Context, repo name.Repository, tags []string, options []remote.Option) (map[string]string, error) {
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(digestWorkers)
+
+	opts := append([]remote.Option{}, options...)
+	opts = append(opts, remote.WithContext(ctx))
+
+	var mu sync.Mutex
+	digests := make(map[string]string, len(tags))
+	for _, tag := range tags {
+		tag := tag
+		g.Go(func() error {
+			desc, err := remote.Head(repo.Tag(tag), opts...)
+			if err != nil {
+				if isManifestNotFound(err) {
+					return nil
+				}
+				return fmt.Errorf("failed to resolve digest of tag '%s': %w", tag, err)
+			}
+			mu.Lock()
+			digests[tag] = desc.Digest.String()
+			mu.Unlock()
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	return digests, nil
+}
+
+// resolveCreated fetches the creation time of the image config of each of the
+// given digests, keyed by tag. The times recorded by the previous scan are
+// reused for the tags that still point at the same digest. For image indexes,
+// the image for the default platform is used. Tags whose manifest or config no
+// longer exists in the registry, or whose image has no creation time, are
+// skipped. Any other error, e.g. a throttled request, fails the resolution.
+func resolveCreated(ctx context.Context, repo name.Repository, digests, previousDigests map[string]string,
+	previousCreated map[string]time.Time, options []remote.Option) (map[string]time.Time, error) {
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(digestWorkers)
+
+	opts := append([]remote.Option{}, options...)
+	opts = append(opts, remote.WithContext(ctx))
+
+	var mu sync.Mutex
+	created := make(map[string]time.Time, len(digests))
+	for tag, digest := range digests {
+		if t, ok := previousCreated[tag]; ok && previousDigests[tag] == digest {
+			created[tag] = t
+			continue
+		}
+
+		tag, digest := tag, digest
+		g.Go(func() error {
+			img, err := remote.Image(repo.Digest(digest), opts...)
+			if err != nil {
+				if isManifestNotFound(err) {
+					return nil
+				}
+				return fmt.Errorf("failed to resolve creation time of tag '%s': %w", tag, err)
+			}
+			cfg, err := img.ConfigFile()
+			if err != nil {
+				if isManifestNotFound(err) {
+					return nil
+				}
+				return fmt.Errorf("failed to resolve creation time of tag '%s': %w", tag, err)
+			}
+			if cfg.Created.IsZero() {
+				return nil
+			}
+			mu.Lock()
+			created[tag] = cfg.Created.Time
+			mu.Unlock()
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// isManifestNotFound returns whether the given error is a registry response
+// for a manifest or blob that doesn't exist.
+func isManifestNotFound(err error) bool {
+	var terr *transport.Error
+	if !errors.As(err, &terr) {
+		return false
+	}
+	if terr.StatusCode == http.StatusNotFound {
+		return true
+	}
+	for _, e := range terr.Errors {
+		if e.Code == transport.ManifestUnknownErrorCode || e.Code == transport.BlobUnknownErrorCode {
+			return true
+		}
+	}
+	return false
+}
+
+// parsePlatforms parses the given platforms, given as `os/arch[/variant]`.
+func parsePlatforms(platforms []string) ([]v1.Platform, error) {
+	var result []v1.Platform
+	for _, p := range platforms {
+		platform, err := v1.ParsePlatform(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid platform '%s': %w", p, err)
+		}
+		if platform.OS == "" || platform.Architecture == "" {
+			return nil, fmt.Errorf("invalid platform '%s': expected os/arch[/variant]", p)
+		}
+		result = append(result, *platform)
+	}
+	return result, nil
+}
+
+// resolvePlatforms fetches the platforms of the images the given digests, keyed
+// by tag, refer to, and returns them keyed by digest. The platforms recorded
+// by the previous scans are reused, as the content of a digest can't change.
+// For image indexes, the platforms of all the images in the index are
+// returned. Digests that don't refer to a container image or image index have
+// no platforms.
+func resolvePlatforms(ctx context.Context, repo name.Repository, digests map[string]string,
+	previousPlatforms map[string][]string, options []remote.Option) (map[string][]string, error) {
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(digestWorkers)
+
+	opts := append([]remote.Option{}, options...)
+	opts = append(opts, remote.WithContext(ctx))
+
+	var mu sync.Mutex
+	platforms := make(map[string][]string, len(digests))
+	seen := make(map[string]struct{}, len(digests))
+	for _, digest := range digests {
+		// Several tags may point at the same digest.
+		if _, ok := seen[digest]; ok {
+			continue
+		}
+		seen[digest] = struct{}{}
+		if p, ok := previousPlatforms[digest]; ok {
+			mu.Lock()
+			platforms[digest] = p
+			mu.Unlock()
+			continue
+		}
+
+		digest := digest
+		g.Go(func() error {
+			p, err := fetchPlatforms(repo.Digest(digest), opts)
+			if err != nil {
+				var terr *transport.Error
+				if !errors.As(err, &terr) || terr.StatusCode != http.StatusNotFound {
+					return fmt.Errorf("failed to resolve platforms of digest '%s': %w", digest, err)
+				}
+			}
+			if p == nil {
+				p = []string{}
+			}
+			mu.Lock()
+			platforms[digest] = p
+			mu.Unlock()
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	return platforms, nil
+}
+
+// fetchPlatforms returns the platforms of the image, or of the images in the
+// image index, the given reference refers to.
+func fetchPlatforms(ref name.Reference, options []remote.Option) ([]string, error) {
+	desc, err := remote.Get(ref, options...)
+	if err != nil {
+		return nil, err
+	}
+	var platforms []string
+	switch {
+	case desc.MediaType.IsIndex():
+		idx, err := desc.ImageIndex()
+		if err != nil {
+			return nil, err
+		}
+		manifest, err := idx.IndexManifest()
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range manifest.Manifests {
+			if m.Platform != nil {
+				platforms = append(platforms, m.Platform.String())
+			}
+		}
+	case desc.MediaType.IsImage():
+		img, err := desc.Image()
+		if err != nil {
+			return nil, err
+		}
+		cfg, err := img.ConfigFile()
+		if err != nil {
+			return nil, err
+		}
+		if p := cfg.Platform(); p != nil {
+			platforms = append(platforms, p.String())
+		}
+	}
+	return platforms, nil
+}
+
+// filterPlatformTags returns the tags pointing at an image, or image index,
+// available for all the given platforms.
+func filterPlatformTags(tags []string, digests map[string]string, digestPlatforms map[string][]string, platforms []v1.Platform) []string {
+	filteredTags := []string{}
+	for _, tag := range tags {
+		digest, ok := digests[tag]
+		if !ok {
+			continue
+		}
+		if hasPlatforms(digestPlatforms[digest], platforms) {
+			filteredTags = append(filteredTags, tag)
+		}
+	}
+	return filteredTags
+}
+
+// hasPlatforms returns true if each of the wanted platforms is satisfied by
+// one of the given platforms.
+func hasPlatforms(have []string, want []v1.Platform) bool {
+	for _, w := range want {
+		found := false
+		for _, h := range have {
+			p, err := v1.ParsePlatform(h)
+			if err == nil && p.Satisfies(w) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// findTagMutations compares the digests of the tags recorded by the previous
+// scan with the current ones and returns the tags that point at a different
+// digest, sorted by tag. Tags that are new or gone are not mutations.
+func findTagMutations(previous, current map[string]string) []imagev1.TagMutation {
+	var mutations []imagev1.TagMutation
+	for tag, digest := range current {
+		if prev, ok := previous[tag]; ok && prev != digest {
+			mutations = append(mutations, imagev1.TagMutation{
+				Tag:            tag,
+				PreviousDigest: prev,
+				Digest:         digest,
+			})
+		}
+	}
+	sort.Slice(mutations, func(i, j int) bool { return mutations[i].Tag < mutations[j].Tag })
+	return mutations
+}
+
 // reconcileDelete handles the deletion of the object.
 func (r *ImageRepositoryReconciler) reconcileDelete(ctx context.Context, obj *imagev1.ImageRepository) (ctrl.Result, error) {
+	// Remove the data of the image from the database, unless another
+	// ImageRepository scans the same image.
+	if canonicalName := obj.Status.CanonicalImageName; canonicalName != "" {
+		var repos imagev1.ImageRepositoryList
+		if err := r.List(ctx, &repos, client.MatchingFields{canonicalImageNameKey: canonicalName}); err != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to list ImageRepositories of %q: %w", canonicalName, err)
+		}
+		shared := false
+		for _, repo := range repos.Items {
+			if repo.Namespace != obj.Namespace || repo.Name != obj.Name {
+				shared = true
+				break
+			}
+		}
+		if !shared {
+			if err := r.Database.Delete(canonicalName); err != nil {
+				return ctrl.Result{}, fmt.Errorf("failed to delete %q from database: %w", canonicalName, err)
+			}
+		}
+	}
+
 	// Remove our finalizer from the list.
 	controllerutil.RemoveFinalizer(obj, imagev1.ImageRepositoryFinalizer)
 
@@ -609,6 +1394,36 @@ func filterOutTags(tags []string, patterns []string) ([]string, error) {
 	return filteredTags, nil
 }
 
+// filterInTags filters the given tags through the given regular expression
+// patterns and returns the tags matching at least one of them. All the tags are
+// returned if there are no patterns.
+func filterInTags(tags []string, patterns []string) ([]string, error) {
+	if len(patterns) == 0 {
+		return tags, nil
+	}
+
+	// Compile all the regex first.
+	compiledRegexp := []*regexp.Regexp{}
+	for _, pattern := range patterns {
+		r, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile regex %s: %w", pattern, err)
+		}
+		compiledRegexp = append(compiledRegexp, r)
+	}
+
+	filteredTags := []string{}
+	for _, tag := range tags {
+		for _, regex := range compiledRegexp {
+			if regex.MatchString(tag) {
+				filteredTags = append(filteredTags, tag)
+				break
+			}
+		}
+	}
+	return filteredTags, nil
+}
+
 // getLatestTags takes a slice of tags, sorts them in descending order of their
 // values and returns the 10 latest tags.
 func getLatestTags(tags []string) []string {
@@ -19,31 +19,51 @@ package controller
 import (
 	"context"
 	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
 	. "github.com/onsi/gomega"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 
 	"github.com/fluxcd/pkg/apis/meta"
 	"github.com/fluxcd/pkg/runtime/conditions"
 
 	imagev1 "github.com/fluxcd/image-reflector-controller/api/v1beta2"
+	"github.com/fluxcd/image-reflector-controller/internal/ratelimit"
 	"github.com/fluxcd/image-reflector-controller/internal/secret"
 	"github.com/fluxcd/image-reflector-controller/internal/test"
 )
 
 // mockDatabase mocks the image repository database.
 type mockDatabase struct {
-	TagData    []string
-	ReadError  error
-	WriteError error
+	TagData       []string
+	DigestData    map[string]string
+	FirstSeenData map[string]time.Time
+	CreatedData   map[string]time.Time
+	PendingData   []string
+	ETagData      string
+	TagsDigest    string
+	PlatformData  map[string][]string
+	RepoData      []string
+	DeletedRepos  []string
+	ReadError     error
+	WriteError    error
 }
 
 // SetTags implements the DatabaseWriter interface of the Database.
@@ -63,6 +83,146 @@ func (db mockDatabase) Tags(repo string) ([]string, error) {
 	return db.TagData, nil
 }
 
+// SetDigests implements the DatabaseWriter interface of the Database.
+func (db *mockDatabase) SetDigests(repo string, digests map[string]string) error {
+	if db.WriteError != nil {
+		return db.WriteError
+	}
+	db.DigestData = digests
+	return nil
+}
+
+// Digests implements the DatabaseReader interface of the Database.
+func (db mockDatabase) Digests(repo string) (map[string]string, error) {
+	if db.ReadError != nil {
+		return nil, db.ReadError
+	}
+	return db.DigestData, nil
+}
+
+// SetFirstSeen implements the DatabaseWriter interface of the Database.
+func (db *mockDatabase) SetFirstSeen(repo string, times map[string]time.Time) error {
+	if db.WriteError != nil {
+		return db.WriteError
+	}
+	db.FirstSeenData = times
+	return nil
+}
+
+// FirstSeen implements the DatabaseReader interface of the Database.
+func (db mockDatabase) FirstSeen(repo string) (map[string]time.Time, error) {
+	if db.ReadError != nil {
+		return nil, db.ReadError
+	}
+	return db.FirstSeenData, nil
+}
+
+// SetCreated implements the DatabaseWriter interface of the Database.
+func (db *mockDatabase) SetCreated(repo string, times map[string]time.Time) error {
+	if db.WriteError != nil {
+		return db.WriteError
+	}
+	db.CreatedData = times
+	return nil
+}
+
+// Created implements the DatabaseReader interface of the Database.
+func (db mockDatabase) Created(repo string) (map[string]time.Time, error) {
+	if db.ReadError != nil {
+		return nil, db.ReadError
+	}
+	return db.CreatedData, nil
+}
+
+// AppendPendingTags implements the DatabaseWriter interface of the Database.
+func (db *mockDatabase) AppendPendingTags(repo string, tags []string) error {
+	if db.WriteError != nil {
+		return db.WriteError
+	}
+	db.PendingData = append(db.PendingData, tags...)
+	return nil
+}
+
+// ClearPendingTags implements the DatabaseWriter interface of the Database.
+func (db *mockDatabase) ClearPendingTags(repo string) error {
+	if db.WriteError != nil {
+		return db.WriteError
+	}
+	db.PendingData = nil
+	return nil
+}
+
+// PendingTags implements the DatabaseReader interface of the Database.
+func (db mockDatabase) PendingTags(repo string) ([]string, error) {
+	if db.ReadError != nil {
+		return nil, db.ReadError
+	}
+	return db.PendingData, nil
+}
+
+// LastPendingTag implements the DatabaseReader interface of the Database.
+func (db mockDatabase) LastPendingTag(repo string) (string, error) {
+	if db.ReadError != nil {
+		return "", db.ReadError
+	}
+	if len(db.PendingData) == 0 {
+		return "", nil
+	}
+	return db.PendingData[len(db.PendingData)-1], nil
+}
+
+// SetTagsValidator implements the DatabaseWriter interface of the Database.
+func (db *mockDatabase) SetTagsValidator(repo, etag, digest string) error {
+	if db.WriteError != nil {
+		return db.WriteError
+	}
+	db.ETagData = etag
+	db.TagsDigest = digest
+	return nil
+}
+
+// TagsValidator implements the DatabaseReader interface of the Database.
+func (db mockDatabase) TagsValidator(repo string) (string, string, error) {
+	if db.ReadError != nil {
+		return "", "", db.ReadError
+	}
+	return db.ETagData, db.TagsDigest, nil
+}
+
+// SetPlatforms implements the DatabaseWriter interface of the Database.
+func (db *mockDatabase) SetPlatforms(repo string, platforms map[string][]string) error {
+	if db.WriteError != nil {
+		return db.WriteError
+	}
+	db.PlatformData = platforms
+	return nil
+}
+
+// Platforms implements the DatabaseReader interface of the Database.
+func (db mockDatabase) Platforms(repo string) (map[string][]string, error) {
+	if db.ReadError != nil {
+		return nil, db.ReadError
+	}
+	return db.PlatformData, nil
+}
+
+// Delete implements the DatabaseWriter interface of the Database.
+func (db *mockDatabase) Delete(repo string) error {
+	if db.WriteError != nil {
+		return db.WriteError
+	}
+	db.DeletedRepos = append(db.DeletedRepos, repo)
+	return nil
+}
+
+// Repositories implements the DatabaseReader interface of the Database.
+func (db mockDatabase) Repositories() ([]string, error) {
+	if db.ReadError != nil {
+		return nil, db.ReadError
+	}
+	return db.RepoData, nil
+}
+
 func TestImageRepositoryReconciler_setAuthOptions(t *testing.T) {
 	testImg := "example.com/foo/bar"
 	testSecretName := "test-secret"
@@ -360,6 +520,38 @@ func TestImageRepositoryReconciler_shouldScan(t *testing.T) {
 			wantNextScan: time.Minute,
 			wantReason:   scanReasonUpdatedExclusionList,
 		},
+		{
+			name:          "inclusion list change",
+			reconcileTime: time.Now(),
+			beforeFunc: func(obj *imagev1.ImageRepository, reconcileTime time.Time) {
+				obj.Status.ObservedExclusionList = obj.GetExclusionList()
+				obj.Spec.InclusionList = []string{"^v"}
+				obj.Status.CanonicalImageName = testImage
+				obj.Status.LastScanResult = &imagev1.ScanResult{
+					ScanTime: metav1.NewTime(reconcileTime.Add(-time.Second * 30)),
+				}
+			},
+			db:           &mockDatabase{TagData: []string{"foo"}},
+			wantScan:     true,
+			wantNextScan: time.Minute,
+			wantReason:   scanReasonUpdatedInclusionList,
+		},
+		{
+			name:          "platforms change",
+			reconcileTime: time.Now(),
+			beforeFunc: func(obj *imagev1.ImageRepository, reconcileTime time.Time) {
+				obj.Status.ObservedExclusionList = obj.GetExclusionList()
+				obj.Spec.Platforms = []string{"linux/arm64"}
+				obj.Status.CanonicalImageName = testImage
+				obj.Status.LastScanResult = &imagev1.ScanResult{
+					ScanTime: metav1.NewTime(reconcileTime.Add(-time.Second * 30)),
+				}
+			},
+			db:           &mockDatabase{TagData: []string{"foo"}},
+			wantScan:     true,
+			wantNextScan: time.Minute,
+			wantReason:   scanReasonUpdatedPlatforms,
+		},
 		{
 			name:          "no tags",
 			reconcileTime: time.Now(),
@@ -389,6 +581,50 @@ func TestImageRepositoryReconciler_shouldScan(t *testing.T) {
 			wantScan:     false,
 			wantNextScan: time.Minute,
 		},
+		{
+			name:          "reflect digests without digests",
+			reconcileTime: time.Now(),
+			beforeFunc: func(obj *imagev1.ImageRepository, reconcileTime time.Time) {
+				obj.Spec.ReflectDigests = true
+				obj.Status.CanonicalImageName = testImage
+				obj.Status.LastScanResult = &imagev1.ScanResult{
+					ScanTime: metav1.NewTime(reconcileTime.Add(-time.Second * 30)),
+				}
+			},
+			db:           &mockDatabase{TagData: []string{"foo"}},
+			wantScan:     true,
+			wantNextScan: time.Minute,
+			wantReason:   scanReasonNoDigests,
+		},
+		{
+			name:          "reflect digests with digests",
+			reconcileTime: time.Now(),
+			beforeFunc: func(obj *imagev1.ImageRepository, reconcileTime time.Time) {
+				obj.Spec.ReflectDigests = true
+				obj.Status.CanonicalImageName = testImage
+				obj.Status.LastScanResult = &imagev1.ScanResult{
+					ScanTime: metav1.NewTime(reconcileTime.Add(-time.Second * 30)),
+				}
+			},
+			db:           &mockDatabase{TagData: []string{"foo"}, DigestData: map[string]string{"foo": "sha256:aaaa"}},
+			wantScan:     false,
+			wantNextScan: time.Second * 30,
+		},
+		{
+			name:          "paginated scan interrupted",
+			reconcileTime: time.Now(),
+			beforeFunc: func(obj *imagev1.ImageRepository, reconcileTime time.Time) {
+				obj.Spec.ScanPageSize = 10
+				obj.Status.CanonicalImageName = testImage
+				obj.Status.LastScanResult = &imagev1.ScanResult{
+					ScanTime: metav1.NewTime(reconcileTime.Add(-time.Second * 30)),
+				}
+			},
+			db:           &mockDatabase{TagData: []string{"foo"}, PendingData: []string{"bar"}},
+			wantScan:     true,
+			wantNextScan: time.Minute,
+			wantReason:   scanReasonInterruptedScan,
+		},
 		{
 			name:          "after the interval",
 			reconcileTime: time.Now(),
@@ -434,22 +670,144 @@ func TestImageRepositoryReconciler_shouldScan(t *testing.T) {
 	}
 }
 
+func TestImageRepositoryReconciler_RequestScan(t *testing.T) {
+	g := NewWithT(t)
+
+	newRepo := func(name, canonicalName string, suspend bool) *imagev1.ImageRepository {
+		obj := &imagev1.ImageRepository{}
+		obj.Name = name
+		obj.Namespace = "default"
+		obj.Spec.Image = canonicalName
+		obj.Spec.Interval = metav1.Duration{Duration: time.Hour}
+		obj.Spec.Suspend = suspend
+		obj.Status.CanonicalImageName = canonicalName
+		obj.Status.ObservedExclusionList = obj.GetExclusionList()
+		obj.Status.LastScanResult = &imagev1.ScanResult{ScanTime: metav1.Now()}
+		return obj
+	}
+	foo := newRepo("foo", "example.com/foo/bar", false)
+	fooSuspended := newRepo("foo-suspended", "example.com/foo/bar", true)
+	baz := newRepo("baz", "example.com/foo/baz", false)
+
+	r := &ImageRepositoryReconciler{
+		Client: fake.NewClientBuilder().
+			WithObjects(foo, fooSuspended, baz).
+			WithIndex(&imagev1.ImageRepository{}, canonicalImageNameKey, indexCanonicalImageName).
+			Build(),
+		Database:   &mockDatabase{TagData: []string{"1.0.0"}},
+		scanEvents: make(chan event.GenericEvent, 10),
+	}
+
+	requested, err := r.RequestScan(context.TODO(), "example.com/foo/bar")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(requested).To(Equal([]types.NamespacedName{client.ObjectKeyFromObject(foo)}))
+	g.Expect(r.scanEvents).To(HaveLen(1))
+
+	scan, _, reason, err := r.shouldScan(*foo, time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(scan).To(BeTrue())
+	g.Expect(reason).To(Equal(scanReasonScanRequested))
+
+	scan, _, _, err = r.shouldScan(*baz, time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(scan).To(BeFalse())
+
+	// A request received during a scan isn't marked done by it.
+	seq, _ := r.scanRequests.get(client.ObjectKeyFromObject(foo))
+	_, err = r.RequestScan(context.TODO(), "example.com/foo/bar")
+	g.Expect(err).ToNot(HaveOccurred())
+	r.scanRequests.done(client.ObjectKeyFromObject(foo), seq)
+	_, ok := r.scanRequests.get(client.ObjectKeyFromObject(foo))
+	g.Expect(ok).To(BeTrue())
+
+	seq, _ = r.scanRequests.get(client.ObjectKeyFromObject(foo))
+	r.scanRequests.done(client.ObjectKeyFromObject(foo), seq)
+	scan, _, _, err = r.shouldScan(*foo, time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(scan).To(BeFalse())
+}
+
+func TestImageRepositoryReconciler_reconcileDelete(t *testing.T) {
+	newRepo := func(name, canonicalName string) *imagev1.ImageRepository {
+		obj := &imagev1.ImageRepository{}
+		obj.Name = name
+		obj.Namespace = "default"
+		obj.Finalizers = []string{imagev1.ImageRepositoryFinalizer}
+		obj.Spec.Image = canonicalName
+		obj.Status.CanonicalImageName = canonicalName
+		return obj
+	}
+
+	tests := []struct {
+		name        string
+		obj         *imagev1.ImageRepository
+		others      []*imagev1.ImageRepository
+		wantDeleted []string
+	}{
+		{
+			name:        "only repository of the image",
+			obj:         newRepo("foo", "example.com/foo/bar"),
+			others:      []*imagev1.ImageRepository{newRepo("baz", "example.com/foo/baz")},
+			wantDeleted: []string{"example.com/foo/bar"},
+		},
+		{
+			name:   "image shared with another repository",
+			obj:    newRepo("foo", "example.com/foo/bar"),
+			others: []*imagev1.ImageRepository{newRepo("other", "example.com/foo/bar")},
+		},
+		{
+			name: "never scanned",
+			obj:  newRepo("foo", ""),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			builder := fake.NewClientBuilder().
+				WithObjects(tt.obj).
+				WithIndex(&imagev1.ImageRepository{}, canonicalImageNameKey, indexCanonicalImageName)
+			for _, other := range tt.others {
+				builder = builder.WithObjects(other)
+			}
+			db := &mockDatabase{}
+			r := &ImageRepositoryReconciler{
+				Client:   builder.Build(),
+				Database: db,
+			}
+
+			_, err := r.reconcileDelete(context.TODO(), tt.obj)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(db.DeletedRepos).To(Equal(tt.wantDeleted))
+			g.Expect(tt.obj.Finalizers).To(BeEmpty())
+		})
+	}
+}
+
 func TestImageRepositoryReconciler_scan(t *testing.T) {
 	registryServer := test.NewRegistryServer()
 	defer registryServer.Close()
 
 	tests := []struct {
-		name           string
-		tags           []string
-		exclusionList  []string
-		annotation     string
-		db             *mockDatabase
-		wantErr        bool
-		wantTags       []string
-		wantLatestTags []string
+		name              string
+		tags              []string
+		exclusionList     []string
+		inclusionList     []string
+		reflectDigests    bool
+		scanPageSize      int
+		annotation        string
+		previouslyScanned bool
+		db                *mockDatabase
+		wantErr           bool
+		wantTags          []string
+		wantLatestTags    []string
+		wantMutated       []string
+		wantFirstSeen     map[string]time.Time
 	}{
 		{
 			name:    "no tags",
+			db:      &mockDatabase{},
 			wantErr: true,
 		},
 		{
@@ -482,10 +840,27 @@ func TestImageRepositoryReconciler_scan(t *testing.T) {
 			wantTags:       []string{"b", "d"},
 			wantLatestTags: []string{"d", "b"},
 		},
+		{
+			name:           "with inclusion and exclusion patterns",
+			tags:           []string{"a1", "a2", "b1", "c"},
+			inclusionList:  []string{"^a", "^b"},
+			exclusionList:  []string{"2$"},
+			db:             &mockDatabase{},
+			wantTags:       []string{"a1", "b1"},
+			wantLatestTags: []string{"b1", "a1"},
+		},
+		{
+			name:          "bad inclusion pattern",
+			tags:          []string{"a"},
+			inclusionList: []string{"[="},
+			db:            &mockDatabase{},
+			wantErr:       true,
+		},
 		{
 			name:          "bad exclusion pattern",
 			tags:          []string{"a"}, // Ensure repo isn't empty to prevent 404.
 			exclusionList: []string{"[="},
+			db:            &mockDatabase{},
 			wantErr:       true,
 		},
 		{
@@ -502,6 +877,66 @@ func TestImageRepositoryReconciler_scan(t *testing.T) {
 			wantTags:       []string{"a", "b"},
 			wantLatestTags: []string{"b", "a"},
 		},
+		{
+			name:           "with digests",
+			tags:           []string{"a", "b", "c"},
+			exclusionList:  []string{"c"},
+			reflectDigests: true,
+			db:             &mockDatabase{},
+			wantTags:       []string{"a", "b"},
+			wantLatestTags: []string{"b", "a"},
+		},
+		{
+			name:           "with digests, mutated tag",
+			tags:           []string{"a", "b"},
+			reflectDigests: true,
+			db:             &mockDatabase{DigestData: map[string]string{"a": "sha256:aaaa", "c": "sha256:cccc"}},
+			wantTags:       []string{"a", "b"},
+			wantLatestTags: []string{"b", "a"},
+			wantMutated:    []string{"a"},
+		},
+		{
+			name:           "paginated",
+			tags:           []string{"a", "b", "c", "d", "e"},
+			exclusionList:  []string{"c"},
+			scanPageSize:   2,
+			db:             &mockDatabase{},
+			wantTags:       []string{"a", "b", "d", "e"},
+			wantLatestTags: []string{"e", "d", "b", "a"},
+		},
+		{
+			name:           "paginated, resumes after pending tags",
+			tags:           []string{"a", "b", "c", "d", "e"},
+			scanPageSize:   2,
+			db:             &mockDatabase{PendingData: []string{"a", "b", "c"}},
+			wantTags:       []string{"a", "b", "c", "d", "e"},
+			wantLatestTags: []string{"e", "d", "c", "b", "a"},
+		},
+		{
+			name:              "first tags after a scan without tags",
+			tags:              []string{"a", "b"},
+			previouslyScanned: true,
+			db:                &mockDatabase{},
+			wantTags:          []string{"a", "b"},
+			wantLatestTags:    []string{"b", "a"},
+			wantFirstSeen:     map[string]time.Time{},
+		},
+		{
+			name:           "keeps first seen times",
+			tags:           []string{"a", "b"},
+			db:             &mockDatabase{FirstSeenData: map[string]time.Time{"a": time.Unix(100, 0), "c": time.Unix(100, 0)}},
+			wantTags:       []string{"a", "b"},
+			wantLatestTags: []string{"b", "a"},
+			wantFirstSeen:  map[string]time.Time{"a": time.Unix(100, 0)},
+		},
+
+		{
+			name:           "without digests clears stale digests",
+			tags:           []string{"a", "b"},
+			db:             &mockDatabase{DigestData: map[string]string{"a": "sha256:aaaa"}},
+			wantTags:       []string{"a", "b"},
+			wantLatestTags: []string{"b", "a"},
+		},
 	}
 
 	for _, tt := range tests {
@@ -519,18 +954,24 @@ func TestImageRepositoryReconciler_scan(t *testing.T) {
 
 			repo := &imagev1.ImageRepository{}
 			repo.Spec = imagev1.ImageRepositorySpec{
-				Image:         imgRepo,
-				ExclusionList: tt.exclusionList,
+				Image:          imgRepo,
+				ExclusionList:  tt.exclusionList,
+				InclusionList:  tt.inclusionList,
+				ReflectDigests: tt.reflectDigests,
+				ScanPageSize:   tt.scanPageSize,
 			}
 
 			if tt.annotation != "" {
 				repo.SetAnnotations(map[string]string{meta.ReconcileRequestAnnotation: tt.annotation})
 			}
+			if tt.previouslyScanned {
+				repo.Status.LastScanResult = &imagev1.ScanResult{ScanTime: metav1.NewTime(time.Now().Add(-time.Hour))}
+			}
 
 			ref, err := parseImageReference(imgRepo)
 			g.Expect(err).ToNot(HaveOccurred())
 
-			opts := []remote.Option{}
+			opts := []remote.Option{remote.WithTransport(&tagListTransport{base: remote.DefaultTransport})}
 
 			tagCount, err := r.scan(context.TODO(), repo, ref, opts)
 			g.Expect(err != nil).To(Equal(tt.wantErr))
@@ -542,7 +983,526 @@ func TestImageRepositoryReconciler_scan(t *testing.T) {
 				if tt.annotation != "" {
 					g.Expect(repo.Status.LastHandledReconcileAt).To(Equal(tt.annotation))
 				}
+
+				var mutated []string
+				for _, m := range repo.Status.MutatedTags {
+					mutated = append(mutated, m.Tag)
+				}
+				g.Expect(mutated).To(Equal(tt.wantMutated))
+				g.Expect(r.Database.PendingTags(imgRepo)).To(BeEmpty())
+
+				// The tags found by the first scan of the repository get the
+				// creation time of their image, zero for the random images of
+				// the test registry.
+				firstSeen, err := r.Database.FirstSeen(imgRepo)
+				g.Expect(err).ToNot(HaveOccurred())
+				g.Expect(firstSeen).To(HaveLen(len(tt.wantTags)))
+				for _, tag := range tt.wantTags {
+					want, ok := tt.wantFirstSeen[tag]
+					if !ok && tt.wantFirstSeen != nil {
+						want = repo.Status.LastScanResult.ScanTime.Time
+					}
+					g.Expect(firstSeen).To(HaveKeyWithValue(tag, want))
+				}
+
+				digests, err := r.Database.Digests(imgRepo)
+				g.Expect(err).ToNot(HaveOccurred())
+				if !tt.reflectDigests {
+					g.Expect(digests).To(BeEmpty())
+					return
+				}
+				g.Expect(digests).To(HaveLen(len(tt.wantTags)))
+				for _, tag := range tt.wantTags {
+					desc, err := remote.Head(ref.Context().Tag(tag))
+					g.Expect(err).ToNot(HaveOccurred())
+					g.Expect(digests).To(HaveKeyWithValue(tag, desc.Digest.String()))
+				}
+			}
+		})
+	}
+}
+
+func TestImageRepositoryReconciler_scanPreexistingTags(t *testing.T) {
+	g := NewWithT(t)
+
+	registryServer := test.NewRegistryServer()
+	defer registryServer.Close()
+
+	// The tags pushed before the first scan are old enough for a minimum age
+	// constraint, whether the creation time of their image is known or not.
+	imgRepo, err := test.LoadImages(registryServer, "test-preexisting-"+randStringRunes(5), []string{"1.0.0"})
+	g.Expect(err).ToNot(HaveOccurred())
+	createdAt := time.Now().Add(-48 * time.Hour).UTC().Truncate(time.Second)
+	img, err := random.Image(512, 1)
+	g.Expect(err).ToNot(HaveOccurred())
+	img, err = mutate.CreatedAt(img, v1.Time{Time: createdAt})
+	g.Expect(err).ToNot(HaveOccurred())
+	tag, err := name.NewTag(imgRepo + ":1.1.0")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(remote.Write(tag, img)).To(Succeed())
+
+	db := &mockDatabase{}
+	repoReconciler := ImageRepositoryReconciler{
+		EventRecorder: record.NewFakeRecorder(32),
+		Database:      db,
+	}
+	policyReconciler := &ImagePolicyReconciler{
+		EventRecorder: record.NewFakeRecorder(32),
+		Database:      db,
+	}
+
+	repo := &imagev1.ImageRepository{}
+	repo.Spec = imagev1.ImageRepositorySpec{
+		Image:          imgRepo,
+		ReflectDigests: true,
+	}
+	repo.Status.CanonicalImageName = imgRepo
+	ref, err := parseImageReference(imgRepo)
+	g.Expect(err).ToNot(HaveOccurred())
+	opts := []remote.Option{remote.WithTransport(&tagListTransport{base: remote.DefaultTransport})}
+
+	policy := &imagev1.ImagePolicy{}
+	policy.Spec.Policy = imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Range: ">=1.0.0"}}
+	policy.Spec.MinAge = &metav1.Duration{Duration: time.Hour}
+
+	_, err = repoReconciler.scan(context.TODO(), repo, ref, opts)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(db.FirstSeen(imgRepo)).To(Equal(map[string]time.Time{
+		"1.0.0": {},
+		"1.1.0": createdAt,
+	}))
+	res, err := policyReconciler.applyPolicy(context.TODO(), policy, repo)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(res.latest).To(Equal("1.1.0"))
+
+	// The tags pushed since are first seen by the following scans.
+	_, err = test.LoadImages(registryServer, strings.TrimPrefix(imgRepo, test.RegistryName(registryServer)+"/"), []string{"1.2.0"})
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = repoReconciler.scan(context.TODO(), repo, ref, opts)
+	g.Expect(err).ToNot(HaveOccurred())
+	firstSeen, err := db.FirstSeen(imgRepo)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(firstSeen).To(HaveKeyWithValue("1.2.0", repo.Status.LastScanResult.ScanTime.Time))
+	res, err = policyReconciler.applyPolicy(context.TODO(), policy, repo)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(res.latest).To(Equal("1.1.0"))
+
+	// A maximum age excludes the tags older than it, and the tags of unknown
+	// age found by the first scan.
+	policy.Spec.MinAge = nil
+	policy.Spec.MaxAge = &metav1.Duration{Duration: 24 * time.Hour}
+	policy.Spec.Policy = imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Range: "<1.2.0"}}
+	_, err = policyReconciler.applyPolicy(context.TODO(), policy, repo)
+	g.Expect(err).To(HaveOccurred())
+	policy.Spec.Policy = imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Range: ">=1.0.0"}}
+	res, err = policyReconciler.applyPolicy(context.TODO(), policy, repo)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(res.latest).To(Equal("1.2.0"))
+}
+
+func TestImageRepositoryReconciler_scanThrottled(t *testing.T) {
+	tests := []struct {
+		name           string
+		retryAfter     string
+		wantRetryAfter time.Duration
+	}{
+		{
+			name:           "retry after seconds",
+			retryAfter:     "30",
+			wantRetryAfter: 30 * time.Second,
+		},
+		{
+			name:           "retry after zero seconds",
+			retryAfter:     "0",
+			wantRetryAfter: ratelimit.MinBackoff,
+		},
+		{
+			name:           "retry after past date",
+			retryAfter:     time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat),
+			wantRetryAfter: ratelimit.MinBackoff,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			registryServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if strings.HasSuffix(r.URL.Path, "/tags/list") {
+					w.Header().Set("Retry-After", tt.retryAfter)
+					w.WriteHeader(http.StatusTooManyRequests)
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer registryServer.Close()
+
+			r := ImageRepositoryReconciler{
+				EventRecorder:   record.NewFakeRecorder(32),
+				Database:        &mockDatabase{},
+				RegistryLimiter: ratelimit.New(ratelimit.Config{}),
+			}
+
+			imgRepo := test.RegistryName(registryServer) + "/foo/bar"
+			repo := &imagev1.ImageRepository{}
+			repo.Spec = imagev1.ImageRepositorySpec{
+				Image: imgRepo,
+			}
+			ref, err := parseImageReference(imgRepo)
+			g.Expect(err).ToNot(HaveOccurred())
+
+			opts := []remote.Option{remote.WithTransport(r.RegistryLimiter.Transport(remote.DefaultTransport))}
+			_, err = r.scan(context.TODO(), repo, ref, opts)
+			var throttled *ratelimit.ThrottledError
+			g.Expect(errors.As(err, &throttled)).To(BeTrue())
+			g.Expect(throttled.RetryAfter).To(Equal(tt.wantRetryAfter))
+		})
+	}
+}
+
+func TestImageRepositoryReconciler_scanUnchanged(t *testing.T) {
+	g := NewWithT(t)
+
+	registryServer := test.NewRegistryServer()
+	defer registryServer.Close()
+
+	imgRepo, err := test.LoadImages(registryServer, "test-unchanged-"+randStringRunes(5), []string{"a", "b"})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	db := &mockDatabase{}
+	r := ImageRepositoryReconciler{
+		EventRecorder: record.NewFakeRecorder(32),
+		Database:      db,
+	}
+
+	repo := &imagev1.ImageRepository{}
+	repo.Spec = imagev1.ImageRepositorySpec{
+		Image: imgRepo,
+	}
+	ref, err := parseImageReference(imgRepo)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	notModified := &notModifiedCounter{base: remote.DefaultTransport}
+	opts := []remote.Option{remote.WithTransport(&tagListTransport{base: notModified})}
+	scan := func() {
+		_, err := r.scan(context.TODO(), repo, ref, opts)
+		g.Expect(err).ToNot(HaveOccurred())
+		repo.Status.CanonicalImageName = ref.Context().String()
+		repo.Status.ObservedExclusionList = repo.GetExclusionList()
+	}
+
+	scan()
+	g.Expect(repo.Status.LastScanResult.Unchanged).To(BeFalse())
+	g.Expect(db.ETagData).ToNot(BeEmpty())
+	g.Expect(db.TagData).To(Equal([]string{"a", "b"}))
+
+	// The registry responds that the tags didn't change, and the tags are not
+	// rewritten.
+	scan()
+	g.Expect(notModified.count).To(Equal(1))
+	g.Expect(repo.Status.LastScanResult.Unchanged).To(BeTrue())
+	g.Expect(repo.Status.LastScanResult.TagCount).To(Equal(2))
+	g.Expect(db.TagData).To(ConsistOf("a", "b"))
+
+	// Changing the exclusion list lists the tags again.
+	repo.Spec.ExclusionList = []string{"b"}
+	scan()
+	g.Expect(notModified.count).To(Equal(1))
+	g.Expect(repo.Status.LastScanResult.Unchanged).To(BeFalse())
+	g.Expect(repo.Status.LastScanResult.TagCount).To(Equal(1))
+
+	_, err = test.LoadImages(registryServer, strings.TrimPrefix(imgRepo, test.RegistryName(registryServer)+"/"), []string{"c"})
+	g.Expect(err).ToNot(HaveOccurred())
+	scan()
+	g.Expect(notModified.count).To(Equal(1))
+	g.Expect(repo.Status.LastScanResult.Unchanged).To(BeFalse())
+	g.Expect(repo.Status.LastScanResult.TagCount).To(Equal(2))
+}
+
+func TestImageRepositoryReconciler_scanPlatforms(t *testing.T) {
+	g := NewWithT(t)
+
+	registryServer := test.NewRegistryServer()
+	defer registryServer.Close()
+
+	imgRepo := test.RegistryName(registryServer) + "/test-platforms-" + randStringRunes(5)
+	ref, err := parseImageReference(imgRepo)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	platformImage := func(platform string) v1.Image {
+		p, err := v1.ParsePlatform(platform)
+		g.Expect(err).ToNot(HaveOccurred())
+		img, err := random.Image(512, 1)
+		g.Expect(err).ToNot(HaveOccurred())
+		cfg, err := img.ConfigFile()
+		g.Expect(err).ToNot(HaveOccurred())
+		cfg.OS, cfg.Architecture, cfg.Variant = p.OS, p.Architecture, p.Variant
+		img, err = mutate.ConfigFile(img, cfg)
+		g.Expect(err).ToNot(HaveOccurred())
+		return img
+	}
+	platformIndex := func(platforms ...string) v1.ImageIndex {
+		var adds []mutate.IndexAddendum
+		for _, platform := range platforms {
+			p, err := v1.ParsePlatform(platform)
+			g.Expect(err).ToNot(HaveOccurred())
+			adds = append(adds, mutate.IndexAddendum{
+				Add:        platformImage(platform),
+				Descriptor: v1.Descriptor{Platform: p},
+			})
+		}
+		return mutate.AppendManifests(empty.Index, adds...)
+	}
+
+	g.Expect(remote.Write(ref.Context().Tag("amd64"), platformImage("linux/amd64"))).To(Succeed())
+	g.Expect(remote.Write(ref.Context().Tag("arm64"), platformImage("linux/arm64"))).To(Succeed())
+	g.Expect(remote.WriteIndex(ref.Context().Tag("multi"), platformIndex("linux/amd64", "linux/arm64/v8"))).To(Succeed())
+	g.Expect(remote.WriteIndex(ref.Context().Tag("multi-amd64"), platformIndex("linux/amd64"))).To(Succeed())
+
+	db := &mockDatabase{}
+	r := ImageRepositoryReconciler{
+		EventRecorder: record.NewFakeRecorder(32),
+		Database:      db,
+	}
+	repo := &imagev1.ImageRepository{}
+	repo.Spec = imagev1.ImageRepositorySpec{
+		Image:     imgRepo,
+		Platforms: []string{"linux/arm64"},
+	}
+
+	tagCount, err := r.scan(context.TODO(), repo, ref, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(tagCount).To(Equal(2))
+	g.Expect(db.TagData).To(ConsistOf("arm64", "multi"))
+	g.Expect(db.DigestData).To(BeEmpty())
+	g.Expect(db.PlatformData).To(HaveLen(4))
+
+	desc, err := remote.Head(ref.Context().Tag("multi"))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(db.PlatformData).To(HaveKeyWithValue(desc.Digest.String(), []string{"linux/amd64", "linux/arm64/v8"}))
+
+	// The platforms recorded for the digests are reused.
+	desc, err = remote.Head(ref.Context().Tag("amd64"))
+	g.Expect(err).ToNot(HaveOccurred())
+	db.PlatformData[desc.Digest.String()] = []string{"linux/arm64"}
+	db.TagData = nil
+	repo.Spec.ReflectDigests = true
+	tagCount, err = r.scan(context.TODO(), repo, ref, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(tagCount).To(Equal(3))
+	g.Expect(db.TagData).To(ConsistOf("amd64", "arm64", "multi"))
+	g.Expect(db.DigestData).To(HaveLen(3))
+
+	repo.Spec.Platforms = []string{"linux"}
+	_, err = r.scan(context.TODO(), repo, ref, nil)
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestResolveCreated(t *testing.T) {
+	g := NewWithT(t)
+
+	registryServer := test.NewRegistryServer()
+	defer registryServer.Close()
+
+	createdAt := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	img, err := random.Image(512, 1)
+	g.Expect(err).ToNot(HaveOccurred())
+	img, err = mutate.CreatedAt(img, v1.Time{Time: createdAt})
+	g.Expect(err).ToNot(HaveOccurred())
+	repo, err := name.NewRepository(test.RegistryName(registryServer) + "/test-created-" + randStringRunes(5))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(remote.Write(repo.Tag("v1"), img)).To(Succeed())
+	digest, err := img.Digest()
+	g.Expect(err).ToNot(HaveOccurred())
+	configName, err := img.ConfigName()
+	g.Expect(err).ToNot(HaveOccurred())
+	digests := map[string]string{"v1": digest.String()}
+
+	tests := []struct {
+		name          string
+		failPath      string
+		failStatus    int
+		wantCreated   map[string]time.Time
+		wantErr       bool
+		wantThrottled bool
+	}{
+		{
+			name:        "created time",
+			wantCreated: map[string]time.Time{"v1": createdAt},
+		},
+		{
+			name:        "manifest not found",
+			failPath:    "/manifests/" + digest.String(),
+			failStatus:  http.StatusNotFound,
+			wantCreated: map[string]time.Time{},
+		},
+		{
+			name:       "manifest error",
+			failPath:   "/manifests/" + digest.String(),
+			failStatus: http.StatusInternalServerError,
+			wantErr:    true,
+		},
+		{
+			name:       "config error",
+			failPath:   "/blobs/" + configName.String(),
+			failStatus: http.StatusInternalServerError,
+			wantErr:    true,
+		},
+		{
+			name:          "throttled",
+			failPath:      "/manifests/" + digest.String(),
+			failStatus:    http.StatusTooManyRequests,
+			wantErr:       true,
+			wantThrottled: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			var rt http.RoundTripper = &failingTransport{
+				base:   remote.DefaultTransport,
+				path:   tt.failPath,
+				status: tt.failStatus,
+			}
+			rt = ratelimit.New(ratelimit.Config{}).Transport(rt)
+			opts := []remote.Option{remote.WithTransport(rt)}
+
+			created, err := resolveCreated(context.TODO(), repo, digests, nil, nil, opts)
+			if tt.wantErr {
+				g.Expect(err).To(HaveOccurred())
+				var throttled *ratelimit.ThrottledError
+				g.Expect(errors.As(err, &throttled)).To(Equal(tt.wantThrottled))
+				return
 			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(created).To(Equal(tt.wantCreated))
+		})
+	}
+}
+
+// failingTransport is a transport responding with the given status to the
+// requests whose path ends with the given path.
+type failingTransport struct {
+	base   http.RoundTripper
+	path   string
+	status int
+}
+
+func (t *failingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.path == "" || !strings.HasSuffix(req.URL.Path, t.path) {
+		return t.base.RoundTrip(req)
+	}
+	header := http.Header{}
+	if t.status == http.StatusTooManyRequests {
+		header.Set("Retry-After", "30")
+	}
+	return &http.Response{
+		StatusCode: t.status,
+		Header:     header,
+		Body:       io.NopCloser(strings.NewReader("")),
+		Request:    req,
+	}, nil
+}
+
+func TestHasPlatforms(t *testing.T) {
+	tests := []struct {
+		name string
+		have []string
+		want []string
+		ok   bool
+	}{
+		{
+			name: "single platform",
+			have: []string{"linux/amd64"},
+			want: []string{"linux/amd64"},
+			ok:   true,
+		},
+		{
+			name: "missing platform",
+			have: []string{"linux/amd64"},
+			want: []string{"linux/amd64", "linux/arm64"},
+		},
+		{
+			name: "any variant",
+			have: []string{"linux/amd64", "linux/arm/v7"},
+			want: []string{"linux/arm"},
+			ok:   true,
+		},
+		{
+			name: "other variant",
+			have: []string{"linux/arm/v6"},
+			want: []string{"linux/arm/v7"},
+		},
+		{
+			name: "no platforms",
+			want: []string{"linux/amd64"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			want, err := parsePlatforms(tt.want)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(hasPlatforms(tt.have, want)).To(Equal(tt.ok))
+		})
+	}
+}
+
+// notModifiedCounter is a transport counting the HTTP 304 responses.
+type notModifiedCounter struct {
+	base  http.RoundTripper
+	count int
+}
+
+func (t *notModifiedCounter) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err == nil && resp.StatusCode == http.StatusNotModified {
+		t.count++
+	}
+	return resp, err
+}
+
+func TestFindTagMutations(t *testing.T) {
+	tests := []struct {
+		name          string
+		previous      map[string]string
+		current       map[string]string
+		wantMutations []imagev1.TagMutation
+	}{
+		{
+			name:    "no previous digests",
+			current: map[string]string{"a": "sha256:aaaa"},
+		},
+		{
+			name:     "no change",
+			previous: map[string]string{"a": "sha256:aaaa", "b": "sha256:bbbb"},
+			current:  map[string]string{"a": "sha256:aaaa", "b": "sha256:bbbb"},
+		},
+		{
+			name:     "new and removed tags",
+			previous: map[string]string{"a": "sha256:aaaa", "b": "sha256:bbbb"},
+			current:  map[string]string{"a": "sha256:aaaa", "c": "sha256:cccc"},
+		},
+		{
+			name:     "mutated tags",
+			previous: map[string]string{"a": "sha256:aaaa", "b": "sha256:bbbb", "c": "sha256:cccc"},
+			current:  map[string]string{"c": "sha256:1111", "a": "sha256:2222", "b": "sha256:bbbb"},
+			wantMutations: []imagev1.TagMutation{
+				{Tag: "a", PreviousDigest: "sha256:aaaa", Digest: "sha256:2222"},
+				{Tag: "c", PreviousDigest: "sha256:cccc", Digest: "sha256:1111"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			g.Expect(findTagMutations(tt.previous, tt.current)).To(Equal(tt.wantMutations))
 		})
 	}
 }
@@ -701,6 +1661,55 @@ func TestFilterOutTags(t *testing.T) {
 	}
 }
 
+func TestFilterInTags(t *testing.T) {
+	tests := []struct {
+		name     string
+		tags     []string
+		patterns []string
+		wantErr  bool
+		wantTags []string
+	}{
+		{
+			name:     "no pattern",
+			tags:     []string{"a", "b", "c", "d"},
+			wantTags: []string{"a", "b", "c", "d"},
+		},
+		{
+			name:     "single pattern",
+			tags:     []string{"v1", "v2", "main-abc", "v3-rc.1"},
+			patterns: []string{`^v\d+$`},
+			wantTags: []string{"v1", "v2"},
+		},
+		{
+			name:     "multiple patterns",
+			tags:     []string{"a", "b", "c", "d"},
+			patterns: []string{"[a]", "[cd]"},
+			wantTags: []string{"a", "c", "d"},
+		},
+		{
+			name:     "no match",
+			tags:     []string{"a", "b"},
+			patterns: []string{"c"},
+			wantTags: []string{},
+		},
+		{
+			name:     "invalid pattern",
+			patterns: []string{"[="},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			result, err := filterInTags(tt.tags, tt.patterns)
+			g.Expect(err != nil).To(Equal(tt.wantErr))
+			g.Expect(result).To(Equal(tt.wantTags))
+		})
+	}
+}
+
 func TestIsEqualSliceContent(t *testing.T) {
 	tests := []struct {
 		name string
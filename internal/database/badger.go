@@ -16,83 +16,160 @@ limitations under the License.
 package database
 
 import (
-	"encoding/json"
-	"fmt"
-
 	"github.com/dgraph-io/badger/v3"
 )
 
-const tagsPrefix = "tags"
+// BadgerBackend is the name of the storage backend storing the database in
+// Badger files. It's the default backend.
+const BadgerBackend = "badger"
 
-// BadgerDatabase provides implementations of the tags database based on Badger.
-type BadgerDatabase struct {
-	db *badger.DB
+func init() {
+	Register(BadgerBackend, openBadger)
+}
+
+// openBadger opens the Badger database in the directory of the options.
+func openBadger(opts Options) (Database, func() error, error) {
+	badgerOpts := badger.DefaultOptions(opts.Path)
+	if opts.ValueLogFileSize > 0 {
+		badgerOpts.ValueLogFileSize = opts.ValueLogFileSize
+	}
+	db, err := badger.Open(badgerOpts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return NewBadgerDatabase(db), db.Close, nil
 }
 
 // NewBadgerDatabase creates and returns a new database implementation using
 // Badger for storing the image tags.
-func NewBadgerDatabase(db *badger.DB) *BadgerDatabase {
-	return &BadgerDatabase{
-		db: db,
-	}
+func NewBadgerDatabase(db *badger.DB) *KVDatabase {
+	return NewKVDatabase(&badgerKV{db: db})
+}
+
+// badgerKV implements the KV interface on Badger. The values of a list are
+// stored under the key of the list suffixed with their index.
+type badgerKV struct {
+	db *badger.DB
 }
 
-// Tags implements the DatabaseReader interface, fetching the tags for the repo.
-//
-// If the repo does not exist, an empty set of tags is returned.
-func (a *BadgerDatabase) Tags(repo string) ([]string, error) {
-	var tags []string
+// Get implements the KV interface.
+func (a *badgerKV) Get(key string) ([]byte, error) {
+	var b []byte
 	err := a.db.View(func(txn *badger.Txn) error {
-		var err error
-		tags, err = getOrEmpty(txn, repo)
+		item, err := txn.Get([]byte(key))
+		if err == badger.ErrKeyNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		b, err = item.ValueCopy(nil)
 		return err
 	})
-	return tags, err
+	return b, err
 }
 
-// SetTags implements the DatabaseWriter interface, recording the tags against
-// the repo.
-//
-// It overwrites existing tag sets for the provided repo.
-func (a *BadgerDatabase) SetTags(repo string, tags []string) error {
-	b, err := marshal(tags)
-	if err != nil {
-		return err
-	}
+// Set implements the KV interface.
+func (a *badgerKV) Set(key string, value []byte) error {
 	return a.db.Update(func(txn *badger.Txn) error {
-		e := badger.NewEntry(keyForRepo(tagsPrefix, repo), b)
-		return txn.SetEntry(e)
+		return txn.SetEntry(badger.NewEntry([]byte(key), value))
 	})
 }
 
-func keyForRepo(prefix, repo string) []byte {
-	return []byte(fmt.Sprintf("%s:%s", prefix, repo))
+// Delete implements the KV interface.
+func (a *badgerKV) Delete(keys ...string) error {
+	return a.db.Update(func(txn *badger.Txn) error {
+		for _, key := range keys {
+			if err := txn.Delete([]byte(key)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
 
-func getOrEmpty(txn *badger.Txn, repo string) ([]string, error) {
-	item, err := txn.Get(keyForRepo(tagsPrefix, repo))
-	if err == badger.ErrKeyNotFound {
-		return []string{}, nil
-	}
-	if err != nil {
-		return nil, err
-	}
-	var tags []string
-	err = item.Value(func(val []byte) error {
-		tags, err = unmarshal(val)
+// Scan implements the KV interface.
+func (a *badgerKV) Scan(prefix string, fn func(key string) error) error {
+	return a.db.View(func(txn *badger.Txn) error {
+		return scanBadgerKeys(txn, prefix, func(key []byte) error {
+			return fn(string(key))
+		})
+	})
+}
+
+// Append implements the KV interface.
+func (a *badgerKV) Append(list string, value []byte) error {
+	return a.db.Update(func(txn *badger.Txn) error {
+		var n int
+		if err := scanBadgerKeys(txn, list, func([]byte) error {
+			n++
+			return nil
+		}); err != nil {
+			return err
+		}
+		return txn.SetEntry(badger.NewEntry([]byte(listKey(list, n)), value))
+	})
+}
+
+// List implements the KV interface.
+func (a *badgerKV) List(list string) ([][]byte, error) {
+	var values [][]byte
+	err := a.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		prefix := []byte(list)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			b, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			values = append(values, b)
+		}
+		return nil
+	})
+	return values, err
+}
+
+// Last implements the KV interface.
+func (a *badgerKV) Last(list string) ([]byte, error) {
+	var b []byte
+	err := a.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Reverse = true
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		// Seeking in reverse finds the greatest key not after the given
+		// one, which follows all the keys of the list.
+		it.Seek([]byte(list + "\xff"))
+		if !it.ValidForPrefix([]byte(list)) {
+			return nil
+		}
+		var err error
+		b, err = it.Item().ValueCopy(nil)
 		return err
 	})
-	return tags, err
+	return b, err
 }
 
-func marshal(t []string) ([]byte, error) {
-	return json.Marshal(t)
+// DeleteList implements the KV interface.
+func (a *badgerKV) DeleteList(list string) error {
+	return a.db.Update(func(txn *badger.Txn) error {
+		return scanBadgerKeys(txn, list, txn.Delete)
+	})
 }
 
-func unmarshal(b []byte) ([]string, error) {
-	var tags []string
-	if err := json.Unmarshal(b, &tags); err != nil {
-		return nil, err
+// scanBadgerKeys calls fn with copies of the keys starting with the given
+// prefix, in order. The keys are copied for fn to be able to delete them.
+func scanBadgerKeys(txn *badger.Txn, prefix string, fn func(key []byte) error) error {
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	it := txn.NewIterator(opts)
+	defer it.Close()
+	p := []byte(prefix)
+	for it.Seek(p); it.ValidForPrefix(p); it.Next() {
+		if err := fn(it.Item().KeyCopy(nil)); err != nil {
+			return err
+		}
 	}
-	return tags, nil
+	return nil
 }
@@ -0,0 +1,192 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package database
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// BoltBackend is the name of the storage backend storing the database in a
+// single bbolt file.
+const BoltBackend = "bbolt"
+
+// boltFileName is the name of the bbolt file in the storage directory.
+const boltFileName = "images.db"
+
+// boltBucket is the bucket holding all the keys of the database.
+var boltBucket = []byte("images")
+
+func init() {
+	Register(BoltBackend, openBolt)
+}
+
+// openBolt opens the bbolt database in the directory of the options, creating
+// it if needed.
+func openBolt(opts Options) (Database, func() error, error) {
+	if err := os.MkdirAll(opts.Path, 0o700); err != nil {
+		return nil, nil, err
+	}
+	db, err := bolt.Open(filepath.Join(opts.Path, boltFileName), 0o600, &bolt.Options{Timeout: 10 * time.Second})
+	if err != nil {
+		return nil, nil, err
+	}
+	a, err := NewBoltDatabase(db)
+	if err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+	return a, db.Close, nil
+}
+
+// NewBoltDatabase creates and returns a new database implementation using
+// bbolt for storing the image tags. The keys and values are the same as with
+// Badger.
+func NewBoltDatabase(db *bolt.DB) (*KVDatabase, error) {
+	if err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(boltBucket)
+		return err
+	}); err != nil {
+		return nil, fmt.Errorf("failed to create bucket: %w", err)
+	}
+	return NewKVDatabase(&boltKV{db: db}), nil
+}
+
+// boltKV implements the KV interface on a bucket of bbolt. The values of a
+// list are stored under the key of the list suffixed with their index.
+type boltKV struct {
+	db *bolt.DB
+}
+
+// Get implements the KV interface.
+func (a *boltKV) Get(key string) ([]byte, error) {
+	var b []byte
+	err := a.db.View(func(tx *bolt.Tx) error {
+		// The value is only valid during the transaction.
+		if v := tx.Bucket(boltBucket).Get([]byte(key)); v != nil {
+			b = append([]byte(nil), v...)
+		}
+		return nil
+	})
+	return b, err
+}
+
+// Set implements the KV interface.
+func (a *boltKV) Set(key string, value []byte) error {
+	return a.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucket).Put([]byte(key), value)
+	})
+}
+
+// Delete implements the KV interface.
+func (a *boltKV) Delete(keys ...string) error {
+	return a.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltBucket)
+		for _, key := range keys {
+			if err := bucket.Delete([]byte(key)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Scan implements the KV interface.
+func (a *boltKV) Scan(prefix string, fn func(key string) error) error {
+	return a.db.View(func(tx *bolt.Tx) error {
+		for _, key := range boltKeysWithPrefix(tx.Bucket(boltBucket), []byte(prefix)) {
+			if err := fn(string(key)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Append implements the KV interface.
+func (a *boltKV) Append(list string, value []byte) error {
+	return a.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltBucket)
+		n := len(boltKeysWithPrefix(bucket, []byte(list)))
+		return bucket.Put([]byte(listKey(list, n)), value)
+	})
+}
+
+// List implements the KV interface.
+func (a *boltKV) List(list string) ([][]byte, error) {
+	var values [][]byte
+	err := a.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(boltBucket).Cursor()
+		prefix := []byte(list)
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			values = append(values, append([]byte(nil), v...))
+		}
+		return nil
+	})
+	return values, err
+}
+
+// Last implements the KV interface.
+func (a *boltKV) Last(list string) ([]byte, error) {
+	var b []byte
+	err := a.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(boltBucket).Cursor()
+		// The keys of the list are followed by the first key after the
+		// given one, if any.
+		k, v := c.Seek([]byte(list + "\xff"))
+		if k == nil {
+			k, v = c.Last()
+		} else {
+			k, v = c.Prev()
+		}
+		if k != nil && bytes.HasPrefix(k, []byte(list)) {
+			b = append([]byte(nil), v...)
+		}
+		return nil
+	})
+	return b, err
+}
+
+// DeleteList implements the KV interface.
+func (a *boltKV) DeleteList(list string) error {
+	return a.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltBucket)
+		for _, key := range boltKeysWithPrefix(bucket, []byte(list)) {
+			if err := bucket.Delete(key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// boltKeysWithPrefix returns copies of the keys of the bucket starting with the
+// given prefix, in order. The keys are copied for the caller to be able to
+// delete them, which can't be done while iterating.
+func boltKeysWithPrefix(bucket *bolt.Bucket, prefix []byte) [][]byte {
+	var keys [][]byte
+	c := bucket.Cursor()
+	for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+		keys = append(keys, append([]byte(nil), k...))
+	}
+	return keys
+}
@@ -0,0 +1,435 @@
+/*
+Copyright 2020 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package database
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+const testRepo = "testing/testing"
+
+// testDatabase runs the conformance tests of the storage backends against
+// the databases returned by newDatabase, each test with a new database.
+func testDatabase(t *testing.T, newDatabase func(t *testing.T) Database) {
+	for _, tt := range []struct {
+		name string
+		test func(t *testing.T, db Database)
+	}{
+		{"GetWithUnknownRepo", testGetWithUnknownRepo},
+		{"SetTags", testSetTags},
+		{"SetTagsOverwrites", testSetTagsOverwrites},
+		{"GetOnlyFetchesForRepo", testGetOnlyFetchesForRepo},
+		{"DigestsWithUnknownRepo", testDigestsWithUnknownRepo},
+		{"SetDigests", testSetDigests},
+		{"SetFirstSeenAndCreated", testSetFirstSeenAndCreated},
+		{"PendingTags", testPendingTags},
+		{"SetTagsValidator", testSetTagsValidator},
+		{"SetPlatforms", testSetPlatforms},
+		{"DeleteAndRepositories", testDeleteAndRepositories},
+		{"SetSchema", testSetSchema},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newDatabase(t))
+		})
+	}
+}
+
+// TestBackends runs the conformance tests against every registered backend.
+func TestBackends(t *testing.T) {
+	for _, name := range Backends() {
+		name := name
+		t.Run(name, func(t *testing.T) {
+			testDatabase(t, func(t *testing.T) Database {
+				opts := Options{Path: t.TempDir()}
+				if name == RedisBackend {
+					opts.URL = "redis://" + miniredis.RunT(t).Addr()
+				}
+				db, closeDB, err := Open(name, opts)
+				fatalIfError(t, err)
+				t.Cleanup(func() { closeDB() })
+				return db
+			})
+		})
+	}
+}
+
+// TestBackendsMigrate runs the migrations on a database of every registered
+// backend populated before schema versions.
+func TestBackendsMigrate(t *testing.T) {
+	for _, name := range Backends() {
+		name := name
+		t.Run(name, func(t *testing.T) {
+			opts := Options{Path: t.TempDir()}
+			if name == RedisBackend {
+				opts.URL = "redis://" + miniredis.RunT(t).Addr()
+			}
+			// Opened without Open, not to be migrated.
+			db, closeDB, err := backends[name](opts)
+			fatalIfError(t, err)
+			defer closeDB()
+
+			digests := map[string]string{"v0.0.1": "sha256:aaaa"}
+			firstSeen := map[string]time.Time{"v0.0.1": time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)}
+			fatalIfError(t, db.SetTags(testRepo, []string{"v0.0.1"}))
+			fatalIfError(t, db.SetDigests(testRepo, digests))
+			fatalIfError(t, db.SetFirstSeen(testRepo, firstSeen))
+			fatalIfError(t, db.AppendPendingTags(testRepo, []string{"v0.0.2"}))
+
+			fatalIfError(t, Migrate(db))
+			latest := migrations[len(migrations)-1]
+			schema, err := db.Schema()
+			fatalIfError(t, err)
+			if want := (Schema{Version: latest.Version, Compatible: latest.Compatible}); schema != want {
+				t.Fatalf("Schema() after Migrate got %#v, want %#v", schema, want)
+			}
+			tags, err := db.Tags(testRepo)
+			fatalIfError(t, err)
+			if want := []string{"v0.0.1"}; !reflect.DeepEqual(want, tags) {
+				t.Errorf("Tags() after Migrate got %#v, want %#v", tags, want)
+			}
+			loadedDigests, err := db.Digests(testRepo)
+			fatalIfError(t, err)
+			if !reflect.DeepEqual(digests, loadedDigests) {
+				t.Errorf("Digests() after Migrate got %#v, want %#v", loadedDigests, digests)
+			}
+			loadedFirstSeen, err := db.FirstSeen(testRepo)
+			fatalIfError(t, err)
+			if !reflect.DeepEqual(firstSeen, loadedFirstSeen) {
+				t.Errorf("FirstSeen() after Migrate got %#v, want %#v", loadedFirstSeen, firstSeen)
+			}
+			pending, err := db.PendingTags(testRepo)
+			fatalIfError(t, err)
+			if want := []string{"v0.0.2"}; !reflect.DeepEqual(want, pending) {
+				t.Errorf("PendingTags() after Migrate got %#v, want %#v", pending, want)
+			}
+
+			// A migration changing the data of the backend.
+			next := append(append([]Migration{}, migrations...), Migration{Version: latest.Version + 1, Compatible: latest.Version + 1, Migrate: upperTags})
+			fatalIfError(t, migrate(db, next))
+			tags, err = db.Tags(testRepo)
+			fatalIfError(t, err)
+			if want := []string{"V0.0.1"}; !reflect.DeepEqual(want, tags) {
+				t.Errorf("Tags() after the next migration got %#v, want %#v", tags, want)
+			}
+		})
+	}
+}
+
+func testGetWithUnknownRepo(t *testing.T, db Database) {
+	tags, err := db.Tags(testRepo)
+	fatalIfError(t, err)
+
+	if !reflect.DeepEqual([]string{}, tags) {
+		t.Fatalf("Tags() for unknown repo got %#v, want %#v", tags, []string{})
+	}
+}
+
+func testSetTags(t *testing.T, db Database) {
+	tags := []string{"latest", "v0.0.1", "v0.0.2"}
+
+	fatalIfError(t, db.SetTags(testRepo, tags))
+
+	loaded, err := db.Tags(testRepo)
+	fatalIfError(t, err)
+	if !reflect.DeepEqual(tags, loaded) {
+		t.Fatalf("SetTags failed, got %#v want %#v", loaded, tags)
+	}
+}
+
+func testSetTagsOverwrites(t *testing.T, db Database) {
+	tags1 := []string{"latest", "v0.0.1", "v0.0.2"}
+	tags2 := []string{"latest", "v0.0.1", "v0.0.2", "v0.0.3"}
+	fatalIfError(t, db.SetTags(testRepo, tags1))
+
+	fatalIfError(t, db.SetTags(testRepo, tags2))
+
+	loaded, err := db.Tags(testRepo)
+	fatalIfError(t, err)
+	if !reflect.DeepEqual(tags2, loaded) {
+		t.Fatalf("failed to overwrite with SetTags: got %#v, want %#v", loaded, tags2)
+	}
+}
+
+func testGetOnlyFetchesForRepo(t *testing.T, db Database) {
+	tags1 := []string{"latest", "v0.0.1", "v0.0.2"}
+	fatalIfError(t, db.SetTags(testRepo, tags1))
+	testRepo2 := "another/repo"
+	tags2 := []string{"v0.0.3", "v0.0.4"}
+	fatalIfError(t, db.SetTags(testRepo2, tags2))
+
+	loaded, err := db.Tags(testRepo)
+	fatalIfError(t, err)
+	if !reflect.DeepEqual(tags1, loaded) {
+		t.Fatalf("Tags() failed got %#v, want %#v", loaded, tags2)
+	}
+}
+
+func testDigestsWithUnknownRepo(t *testing.T, db Database) {
+	digests, err := db.Digests(testRepo)
+	fatalIfError(t, err)
+
+	if !reflect.DeepEqual(map[string]string{}, digests) {
+		t.Fatalf("Digests() for unknown repo got %#v, want %#v", digests, map[string]string{})
+	}
+}
+
+func testSetDigests(t *testing.T, db Database) {
+	digests1 := map[string]string{"latest": "sha256:aaaa", "v0.0.1": "sha256:bbbb"}
+	digests2 := map[string]string{"latest": "sha256:cccc"}
+	fatalIfError(t, db.SetDigests(testRepo, digests1))
+	fatalIfError(t, db.SetTags(testRepo, []string{"latest", "v0.0.1"}))
+
+	loaded, err := db.Digests(testRepo)
+	fatalIfError(t, err)
+	if !reflect.DeepEqual(digests1, loaded) {
+		t.Fatalf("SetDigests failed, got %#v want %#v", loaded, digests1)
+	}
+
+	fatalIfError(t, db.SetDigests(testRepo, digests2))
+	loaded, err = db.Digests(testRepo)
+	fatalIfError(t, err)
+	if !reflect.DeepEqual(digests2, loaded) {
+		t.Fatalf("failed to overwrite with SetDigests: got %#v, want %#v", loaded, digests2)
+	}
+}
+
+func testSetFirstSeenAndCreated(t *testing.T, db Database) {
+	firstSeen := map[string]time.Time{"latest": time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)}
+	created := map[string]time.Time{"latest": time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+	loaded, err := db.FirstSeen(testRepo)
+	fatalIfError(t, err)
+	if len(loaded) != 0 {
+		t.Fatalf("FirstSeen() for unknown repo got %#v, want empty", loaded)
+	}
+
+	fatalIfError(t, db.SetFirstSeen(testRepo, firstSeen))
+	fatalIfError(t, db.SetCreated(testRepo, created))
+
+	loaded, err = db.FirstSeen(testRepo)
+	fatalIfError(t, err)
+	if !reflect.DeepEqual(firstSeen, loaded) {
+		t.Fatalf("SetFirstSeen failed, got %#v want %#v", loaded, firstSeen)
+	}
+	loaded, err = db.Created(testRepo)
+	fatalIfError(t, err)
+	if !reflect.DeepEqual(created, loaded) {
+		t.Fatalf("SetCreated failed, got %#v want %#v", loaded, created)
+	}
+}
+
+func testPendingTags(t *testing.T, db Database) {
+	loaded, err := db.PendingTags(testRepo)
+	fatalIfError(t, err)
+	if len(loaded) != 0 {
+		t.Fatalf("PendingTags() for unknown repo got %#v, want empty", loaded)
+	}
+	last, err := db.LastPendingTag(testRepo)
+	fatalIfError(t, err)
+	if last != "" {
+		t.Fatalf("LastPendingTag() for unknown repo got %q, want empty", last)
+	}
+
+	var want []string
+	for i := 0; i < 12; i++ {
+		page := []string{fmt.Sprintf("v%02d.0", i), fmt.Sprintf("v%02d.1", i)}
+		fatalIfError(t, db.AppendPendingTags(testRepo, page))
+		want = append(want, page...)
+	}
+	fatalIfError(t, db.AppendPendingTags(testRepo+"/other", []string{"other"}))
+
+	loaded, err = db.PendingTags(testRepo)
+	fatalIfError(t, err)
+	if !reflect.DeepEqual(want, loaded) {
+		t.Fatalf("AppendPendingTags failed, got %#v want %#v", loaded, want)
+	}
+	last, err = db.LastPendingTag(testRepo)
+	fatalIfError(t, err)
+	if last != "v11.1" {
+		t.Fatalf("LastPendingTag() got %q, want %q", last, "v11.1")
+	}
+
+	fatalIfError(t, db.ClearPendingTags(testRepo))
+	loaded, err = db.PendingTags(testRepo)
+	fatalIfError(t, err)
+	if len(loaded) != 0 {
+		t.Fatalf("ClearPendingTags failed, got %#v want empty", loaded)
+	}
+	last, err = db.LastPendingTag(testRepo)
+	fatalIfError(t, err)
+	if last != "" {
+		t.Fatalf("LastPendingTag() after ClearPendingTags got %q, want empty", last)
+	}
+	loaded, err = db.PendingTags(testRepo + "/other")
+	fatalIfError(t, err)
+	if !reflect.DeepEqual([]string{"other"}, loaded) {
+		t.Fatalf("ClearPendingTags removed the tags of another repo, got %#v", loaded)
+	}
+}
+
+func testSetTagsValidator(t *testing.T, db Database) {
+	etag, digest, err := db.TagsValidator(testRepo)
+	fatalIfError(t, err)
+	if etag != "" || digest != "" {
+		t.Fatalf("TagsValidator() for unknown repo got (%q, %q), want empty", etag, digest)
+	}
+
+	fatalIfError(t, db.SetTagsValidator(testRepo, `"abc"`, "sha256:aaaa"))
+	etag, digest, err = db.TagsValidator(testRepo)
+	fatalIfError(t, err)
+	if etag != `"abc"` || digest != "sha256:aaaa" {
+		t.Fatalf("SetTagsValidator failed, got (%q, %q)", etag, digest)
+	}
+}
+
+func testSetPlatforms(t *testing.T, db Database) {
+	platforms, err := db.Platforms(testRepo)
+	fatalIfError(t, err)
+	if !reflect.DeepEqual(map[string][]string{}, platforms) {
+		t.Fatalf("Platforms() for unknown repo got %#v, want %#v", platforms, map[string][]string{})
+	}
+
+	platforms1 := map[string][]string{"sha256:aaaa": {"linux/amd64", "linux/arm64"}, "sha256:bbbb": {}}
+	fatalIfError(t, db.SetPlatforms(testRepo, platforms1))
+	loaded, err := db.Platforms(testRepo)
+	fatalIfError(t, err)
+	if !reflect.DeepEqual(platforms1, loaded) {
+		t.Fatalf("SetPlatforms failed, got %#v want %#v", loaded, platforms1)
+	}
+
+	platforms2 := map[string][]string{"sha256:cccc": {"linux/arm/v7"}}
+	fatalIfError(t, db.SetPlatforms(testRepo, platforms2))
+	loaded, err = db.Platforms(testRepo)
+	fatalIfError(t, err)
+	if !reflect.DeepEqual(platforms2, loaded) {
+		t.Fatalf("failed to overwrite with SetPlatforms: got %#v, want %#v", loaded, platforms2)
+	}
+}
+
+func testDeleteAndRepositories(t *testing.T, db Database) {
+	repos, err := db.Repositories()
+	fatalIfError(t, err)
+	if len(repos) != 0 {
+		t.Fatalf("Repositories() for empty database got %#v, want empty", repos)
+	}
+
+	other := "localhost:5000/testing/other"
+	for _, repo := range []string{testRepo, other} {
+		fatalIfError(t, db.SetTags(repo, []string{"v0.0.1"}))
+		fatalIfError(t, db.SetDigests(repo, map[string]string{"v0.0.1": "sha256:aaaa"}))
+		fatalIfError(t, db.SetFirstSeen(repo, map[string]time.Time{"v0.0.1": time.Now()}))
+		fatalIfError(t, db.SetCreated(repo, map[string]time.Time{"v0.0.1": time.Now()}))
+		fatalIfError(t, db.SetTagsValidator(repo, `"abc"`, "sha256:bbbb"))
+		fatalIfError(t, db.SetPlatforms(repo, map[string][]string{"sha256:aaaa": {"linux/amd64"}}))
+		fatalIfError(t, db.AppendPendingTags(repo, []string{"v0.0.2"}))
+	}
+	fatalIfError(t, db.AppendPendingTags("localhost:5000/pending", []string{"v0.0.1"}))
+
+	repos, err = db.Repositories()
+	fatalIfError(t, err)
+	want := []string{"localhost:5000/pending", other, testRepo}
+	if !reflect.DeepEqual(want, repos) {
+		t.Fatalf("Repositories() got %#v, want %#v", repos, want)
+	}
+
+	fatalIfError(t, db.Delete(other))
+	fatalIfError(t, db.Delete("localhost:5000/pending"))
+	// Deleting an unknown repo is a no-op.
+	fatalIfError(t, db.Delete("unknown"))
+
+	repos, err = db.Repositories()
+	fatalIfError(t, err)
+	if !reflect.DeepEqual([]string{testRepo}, repos) {
+		t.Fatalf("Delete failed, Repositories() got %#v, want %#v", repos, []string{testRepo})
+	}
+	tags, err := db.Tags(other)
+	fatalIfError(t, err)
+	if len(tags) != 0 {
+		t.Fatalf("Delete failed, Tags() got %#v, want empty", tags)
+	}
+	tags, err = db.Tags(testRepo)
+	fatalIfError(t, err)
+	if !reflect.DeepEqual([]string{"v0.0.1"}, tags) {
+		t.Fatalf("Delete removed the tags of another repo, got %#v", tags)
+	}
+}
+
+func testSetSchema(t *testing.T, db Database) {
+	fatalIfError(t, db.SetSchema(Schema{}))
+	schema, err := db.Schema()
+	fatalIfError(t, err)
+	if schema != (Schema{}) {
+		t.Fatalf("Schema() got %#v, want %#v", schema, Schema{})
+	}
+
+	want := Schema{Version: 2, Compatible: 1}
+	fatalIfError(t, db.SetSchema(want))
+	schema, err = db.Schema()
+	fatalIfError(t, err)
+	if schema != want {
+		t.Fatalf("SetSchema failed, got %#v want %#v", schema, want)
+	}
+
+	repos, err := db.Repositories()
+	fatalIfError(t, err)
+	if len(repos) != 0 {
+		t.Fatalf("Repositories() lists the schema, got %#v", repos)
+	}
+}
+
+func fatalIfError(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOpenMigrates(t *testing.T) {
+	dir := t.TempDir()
+	db, closeDB, err := Open(BoltBackend, Options{Path: dir})
+	fatalIfError(t, err)
+	schema, err := db.Schema()
+	fatalIfError(t, err)
+	if schema.Version != SchemaVersion {
+		t.Fatalf("Schema() of new database got %#v, want version %d", schema, SchemaVersion)
+	}
+
+	// A database written by a newer, incompatible, version can't be opened.
+	fatalIfError(t, db.SetSchema(Schema{Version: SchemaVersion + 1, Compatible: SchemaVersion + 1}))
+	fatalIfError(t, closeDB())
+	if _, _, err := Open(BoltBackend, Options{Path: dir}); !errors.Is(err, ErrIncompatibleSchema) {
+		t.Fatalf("Open() of newer database error = %v, want %v", err, ErrIncompatibleSchema)
+	}
+}
+
+func TestOpenRedisWithoutURL(t *testing.T) {
+	if _, _, err := Open(RedisBackend, Options{}); err == nil {
+		t.Fatal("Open() of the Redis backend without URL succeeded, want error")
+	}
+}
+
+func TestOpenUnknownBackend(t *testing.T) {
+	if _, _, err := Open("unknown", Options{Path: t.TempDir()}); err == nil {
+		t.Fatal("Open() with an unknown backend succeeded, want error")
+	}
+}
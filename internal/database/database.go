@@ -0,0 +1,116 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package database
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Database is implemented by the storage backends of the image metadata. It
+// satisfies the DatabaseReader and DatabaseWriter interfaces of the
+// controllers.
+type Database interface {
+	Tags(repo string) ([]string, error)
+	SetTags(repo string, tags []string) error
+	Digests(repo string) (map[string]string, error)
+	SetDigests(repo string, digests map[string]string) error
+	FirstSeen(repo string) (map[string]time.Time, error)
+	SetFirstSeen(repo string, times map[string]time.Time) error
+	Created(repo string) (map[string]time.Time, error)
+	SetCreated(repo string, times map[string]time.Time) error
+	PendingTags(repo string) ([]string, error)
+	LastPendingTag(repo string) (string, error)
+	AppendPendingTags(repo string, tags []string) error
+	ClearPendingTags(repo string) error
+	TagsValidator(repo string) (etag, digest string, err error)
+	SetTagsValidator(repo, etag, digest string) error
+	Platforms(repo string) (map[string][]string, error)
+	SetPlatforms(repo string, platforms map[string][]string) error
+	Repositories() ([]string, error)
+	Delete(repo string) error
+	Schema() (Schema, error)
+	SetSchema(s Schema) error
+}
+
+// Options configures the storage backends. Each backend uses the options
+// relevant to it.
+type Options struct {
+	// Path is the directory where the database files are stored.
+	Path string
+	// ValueLogFileSize is the size of the memory mapped value log files of
+	// the Badger backend, in bytes.
+	ValueLogFileSize int64
+	// URL is the address of the server of the networked backends, e.g.
+	// redis://host:6379/0.
+	URL string
+}
+
+// OpenFunc opens a database with the given options. It returns the database,
+// and a function closing it.
+type OpenFunc func(opts Options) (Database, func() error, error)
+
+var (
+	backendsMu sync.RWMutex
+	backends   = map[string]OpenFunc{}
+)
+
+// Register makes a storage backend available by the given name. It panics if
+// a backend is already registered by the name.
+func Register(name string, open OpenFunc) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+	if _, ok := backends[name]; ok {
+		panic(fmt.Sprintf("storage backend %q registered twice", name))
+	}
+	backends[name] = open
+}
+
+// Backends returns the names of the registered storage backends, in order.
+func Backends() []string {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Open opens a database with the storage backend registered by the given
+// name, and migrates its data to the current schema version.
+func Open(name string, opts Options) (Database, func() error, error) {
+	backendsMu.RLock()
+	open, ok := backends[name]
+	backendsMu.RUnlock()
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown storage backend %q, must be one of: %s", name, strings.Join(Backends(), ", "))
+	}
+	db, closeDB, err := open(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := Migrate(db); err != nil {
+		closeDB()
+		return nil, nil, err
+	}
+	return db, closeDB, nil
+}
@@ -0,0 +1,385 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	tagsPrefix      = "tags"
+	digestsPrefix   = "digests"
+	firstSeenPrefix = "firstseen"
+	createdPrefix   = "created"
+	pagesPrefix     = "pages"
+	validatorPrefix = "validator"
+	platformsPrefix = "platforms"
+)
+
+// repoPrefixes are the prefixes of the keys of the values recorded for a repo,
+// the list of its pending tag pages aside.
+var repoPrefixes = []string{tagsPrefix, digestsPrefix, firstSeenPrefix, createdPrefix, validatorPrefix, platformsPrefix}
+
+// KV is the key-value store of a storage backend. The data of the repos is
+// laid out in it by KVDatabase, the same way for all the backends.
+type KV interface {
+	// Get returns the value of the key, or nil if the key doesn't exist.
+	Get(key string) ([]byte, error)
+	// Set sets the value of the key.
+	Set(key string, value []byte) error
+	// Delete removes the given keys. Missing keys are ignored.
+	Delete(keys ...string) error
+	// Scan calls fn with the keys starting with the given prefix, the keys
+	// holding the values of the lists included.
+	Scan(prefix string, fn func(key string) error) error
+	// Append appends the value to the list with the given key, without
+	// rewriting the values appended before.
+	Append(list string, value []byte) error
+	// List returns the values of the list with the given key, in the order
+	// they were appended. It returns no values if the list doesn't exist.
+	List(list string) ([][]byte, error)
+	// Last returns the value appended last to the list with the given key,
+	// or nil if the list doesn't exist.
+	Last(list string) ([]byte, error)
+	// DeleteList removes the list with the given key.
+	DeleteList(list string) error
+}
+
+// KVDatabase implements the Database interface on the key-value store of a
+// storage backend. The values are marshalled as JSON under keys made of a
+// prefix and the repo, and the pending tag pages of a repo are stored in a
+// list.
+type KVDatabase struct {
+	kv KV
+}
+
+// NewKVDatabase creates and returns a new database storing the image tags in
+// the given key-value store.
+func NewKVDatabase(kv KV) *KVDatabase {
+	return &KVDatabase{
+		kv: kv,
+	}
+}
+
+// Tags implements the DatabaseReader interface, fetching the tags for the repo.
+//
+// If the repo does not exist, an empty set of tags is returned.
+func (a *KVDatabase) Tags(repo string) ([]string, error) {
+	tags := []string{}
+	if err := a.getValue(tagsPrefix, repo, &tags); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// SetTags implements the DatabaseWriter interface, recording the tags against
+// the repo.
+//
+// It overwrites existing tag sets for the provided repo.
+func (a *KVDatabase) SetTags(repo string, tags []string) error {
+	return a.setValue(tagsPrefix, repo, tags)
+}
+
+// Digests implements the DatabaseReader interface, fetching the manifest
+// digests recorded for the tags of the repo.
+//
+// If the repo does not exist, an empty set of digests is returned.
+func (a *KVDatabase) Digests(repo string) (map[string]string, error) {
+	digests := map[string]string{}
+	if err := a.getValue(digestsPrefix, repo, &digests); err != nil {
+		return nil, err
+	}
+	return digests, nil
+}
+
+// SetDigests implements the DatabaseWriter interface, recording the manifest
+// digests of the tags against the repo.
+//
+// It overwrites existing digests for the provided repo.
+func (a *KVDatabase) SetDigests(repo string, digests map[string]string) error {
+	return a.setValue(digestsPrefix, repo, digests)
+}
+
+// FirstSeen implements the DatabaseReader interface, fetching the time each
+// tag of the repo was first seen.
+//
+// If the repo does not exist, an empty set of times is returned.
+func (a *KVDatabase) FirstSeen(repo string) (map[string]time.Time, error) {
+	times := map[string]time.Time{}
+	if err := a.getValue(firstSeenPrefix, repo, &times); err != nil {
+		return nil, err
+	}
+	return times, nil
+}
+
+// SetFirstSeen implements the DatabaseWriter interface, recording the time
+// each tag was first seen against the repo.
+//
+// It overwrites existing times for the provided repo.
+func (a *KVDatabase) SetFirstSeen(repo string, times map[string]time.Time) error {
+	return a.setValue(firstSeenPrefix, repo, times)
+}
+
+// Created implements the DatabaseReader interface, fetching the creation time
+// of the image each tag of the repo points at.
+//
+// If the repo does not exist, an empty set of times is returned.
+func (a *KVDatabase) Created(repo string) (map[string]time.Time, error) {
+	times := map[string]time.Time{}
+	if err := a.getValue(createdPrefix, repo, &times); err != nil {
+		return nil, err
+	}
+	return times, nil
+}
+
+// SetCreated implements the DatabaseWriter interface, recording the creation
+// time of the image each tag points at against the repo.
+//
+// It overwrites existing times for the provided repo.
+func (a *KVDatabase) SetCreated(repo string, times map[string]time.Time) error {
+	return a.setValue(createdPrefix, repo, times)
+}
+
+// PendingTags implements the DatabaseReader interface, fetching the tags
+// appended for the repo by a scan that hasn't completed, in the order they were
+// appended.
+//
+// If the repo does not exist, an empty set of tags is returned.
+func (a *KVDatabase) PendingTags(repo string) ([]string, error) {
+	pages, err := a.kv.List(pagesKeyPrefix(repo))
+	if err != nil {
+		return nil, err
+	}
+	tags := []string{}
+	for _, b := range pages {
+		page, err := unmarshal(b)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, page...)
+	}
+	return tags, nil
+}
+
+// LastPendingTag implements the DatabaseReader interface, fetching the last tag
+// of the page appended last for the repo by a scan that hasn't completed,
+// without reading the other pages.
+//
+// If the repo does not exist, an empty tag is returned.
+func (a *KVDatabase) LastPendingTag(repo string) (string, error) {
+	b, err := a.kv.Last(pagesKeyPrefix(repo))
+	if err != nil || b == nil {
+		return "", err
+	}
+	page, err := unmarshal(b)
+	if err != nil || len(page) == 0 {
+		return "", err
+	}
+	return page[len(page)-1], nil
+}
+
+// AppendPendingTags implements the DatabaseWriter interface, appending a page
+// of tags to the pending tags of the repo.
+//
+// Every page is stored as a separate value, so that appending a page doesn't
+// rewrite the pages appended before.
+func (a *KVDatabase) AppendPendingTags(repo string, tags []string) error {
+	b, err := marshal(tags)
+	if err != nil {
+		return err
+	}
+	return a.kv.Append(pagesKeyPrefix(repo), b)
+}
+
+// ClearPendingTags implements the DatabaseWriter interface, removing all the
+// pending tags of the repo.
+func (a *KVDatabase) ClearPendingTags(repo string) error {
+	return a.kv.DeleteList(pagesKeyPrefix(repo))
+}
+
+// tagsValidator is the validator of the tags stored for a repo.
+type tagsValidator struct {
+	ETag   string `json:"etag,omitempty"`
+	Digest string `json:"digest,omitempty"`
+}
+
+// TagsValidator implements the DatabaseReader interface, fetching the ETag of
+// the tag list response and the digest of the tags recorded for the repo.
+//
+// If the repo does not exist, empty values are returned.
+func (a *KVDatabase) TagsValidator(repo string) (string, string, error) {
+	var v tagsValidator
+	if err := a.getValue(validatorPrefix, repo, &v); err != nil {
+		return "", "", err
+	}
+	return v.ETag, v.Digest, nil
+}
+
+// SetTagsValidator implements the DatabaseWriter interface, recording the
+// ETag of the tag list response and the digest of the tags against the repo.
+//
+// It overwrites the existing values for the provided repo.
+func (a *KVDatabase) SetTagsValidator(repo, etag, digest string) error {
+	return a.setValue(validatorPrefix, repo, tagsValidator{ETag: etag, Digest: digest})
+}
+
+// Platforms implements the DatabaseReader interface, fetching the platforms
+// recorded for the manifest digests of the repo.
+//
+// If the repo does not exist, an empty set of platforms is returned.
+func (a *KVDatabase) Platforms(repo string) (map[string][]string, error) {
+	platforms := map[string][]string{}
+	if err := a.getValue(platformsPrefix, repo, &platforms); err != nil {
+		return nil, err
+	}
+	return platforms, nil
+}
+
+// SetPlatforms implements the DatabaseWriter interface, recording the
+// platforms of the manifest digests against the repo.
+//
+// It overwrites existing platforms for the provided repo.
+func (a *KVDatabase) SetPlatforms(repo string, platforms map[string][]string) error {
+	return a.setValue(platformsPrefix, repo, platforms)
+}
+
+// Schema implements the Database interface, fetching the schema of the
+// recorded data.
+//
+// If no schema is recorded, the zero schema is returned.
+func (a *KVDatabase) Schema() (Schema, error) {
+	var s Schema
+	if err := a.getValue(schemaPrefix, schemaRepo, &s); err != nil {
+		return Schema{}, err
+	}
+	return s, nil
+}
+
+// SetSchema implements the Database interface, recording the schema of the
+// data.
+func (a *KVDatabase) SetSchema(s Schema) error {
+	return a.setValue(schemaPrefix, schemaRepo, s)
+}
+
+// Delete implements the DatabaseWriter interface, removing all the data
+// recorded for the repo.
+func (a *KVDatabase) Delete(repo string) error {
+	var keys []string
+	for _, prefix := range repoPrefixes {
+		keys = append(keys, keyForRepo(prefix, repo))
+	}
+	if err := a.kv.Delete(keys...); err != nil {
+		return err
+	}
+	return a.kv.DeleteList(pagesKeyPrefix(repo))
+}
+
+// Repositories implements the DatabaseReader interface, listing the repos
+// with recorded data, in order.
+func (a *KVDatabase) Repositories() ([]string, error) {
+	var repos []string
+	seen := map[string]bool{}
+	if err := a.kv.Scan("", func(key string) error {
+		repo, ok := repoForKey(key)
+		if ok && !seen[repo] {
+			seen[repo] = true
+			repos = append(repos, repo)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	sort.Strings(repos)
+	return repos, nil
+}
+
+// getValue unmarshals the value stored for the repo under the given prefix
+// into v. v is left untouched if there's no value stored.
+func (a *KVDatabase) getValue(prefix, repo string, v interface{}) error {
+	b, err := a.kv.Get(keyForRepo(prefix, repo))
+	if err != nil || b == nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// setValue marshals v and stores it for the repo under the given prefix.
+func (a *KVDatabase) setValue(prefix, repo string, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return a.kv.Set(keyForRepo(prefix, repo), b)
+}
+
+func keyForRepo(prefix, repo string) string {
+	return fmt.Sprintf("%s:%s", prefix, repo)
+}
+
+// repoForKey returns the repo of the given key, and whether it is a key of a
+// repo.
+func repoForKey(key string) (string, bool) {
+	prefix, repo, ok := strings.Cut(key, ":")
+	if !ok {
+		return "", false
+	}
+	if prefix == pagesPrefix {
+		// Repos may contain colons, e.g. with a registry port, unlike the
+		// page numbers suffixing the keys.
+		i := strings.LastIndex(repo, ":")
+		if i < 0 {
+			return "", false
+		}
+		return repo[:i], true
+	}
+	for _, p := range repoPrefixes {
+		if prefix == p {
+			return repo, true
+		}
+	}
+	return "", false
+}
+
+// pagesKeyPrefix returns the key of the list of the pending tag pages of the
+// repo. It ends with a colon, for the backends storing every page under the
+// key suffixed with the page number to keep the keys of the repo apart.
+func pagesKeyPrefix(repo string) string {
+	return keyForRepo(pagesPrefix, repo) + ":"
+}
+
+// listKey returns the key of the value with the given index in the list with
+// the given key, for the backends storing every value of a list under its own
+// key. The index is padded for the keys to sort in order.
+func listKey(list string, i int) string {
+	return fmt.Sprintf("%s%08d", list, i)
+}
+
+func marshal(t []string) ([]byte, error) {
+	return json.Marshal(t)
+}
+
+func unmarshal(b []byte) ([]string, error) {
+	var tags []string
+	if err := json.Unmarshal(b, &tags); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
@@ -0,0 +1,138 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package database
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MemoryBackend is the name of the storage backend keeping the database in
+// memory. The data is lost when the controller restarts.
+const MemoryBackend = "memory"
+
+func init() {
+	Register(MemoryBackend, func(Options) (Database, func() error, error) {
+		return NewMemoryDatabase(), func() error { return nil }, nil
+	})
+}
+
+// NewMemoryDatabase creates and returns a new, empty, in-memory database.
+func NewMemoryDatabase() *KVDatabase {
+	return NewKVDatabase(&memoryKV{data: map[string][]byte{}})
+}
+
+// memoryKV implements the KV interface on a map. The values are stored
+// marshalled, as with the other backends, so that the callers never share
+// them. The values of a list are stored under the key of the list suffixed
+// with their index.
+type memoryKV struct {
+	mu   sync.RWMutex
+	data map[string][]byte
+}
+
+// Get implements the KV interface.
+func (a *memoryKV) Get(key string) ([]byte, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.data[key], nil
+}
+
+// Set implements the KV interface.
+func (a *memoryKV) Set(key string, value []byte) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.data[key] = value
+	return nil
+}
+
+// Delete implements the KV interface.
+func (a *memoryKV) Delete(keys ...string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, key := range keys {
+		delete(a.data, key)
+	}
+	return nil
+}
+
+// Scan implements the KV interface.
+func (a *memoryKV) Scan(prefix string, fn func(key string) error) error {
+	a.mu.RLock()
+	keys := a.keysWithPrefix(prefix)
+	a.mu.RUnlock()
+	for _, key := range keys {
+		if err := fn(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Append implements the KV interface.
+func (a *memoryKV) Append(list string, value []byte) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.data[listKey(list, len(a.keysWithPrefix(list)))] = value
+	return nil
+}
+
+// List implements the KV interface.
+func (a *memoryKV) List(list string) ([][]byte, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	var values [][]byte
+	for _, key := range a.keysWithPrefix(list) {
+		values = append(values, a.data[key])
+	}
+	return values, nil
+}
+
+// Last implements the KV interface.
+func (a *memoryKV) Last(list string) ([]byte, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	keys := a.keysWithPrefix(list)
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	return a.data[keys[len(keys)-1]], nil
+}
+
+// DeleteList implements the KV interface.
+func (a *memoryKV) DeleteList(list string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, key := range a.keysWithPrefix(list) {
+		delete(a.data, key)
+	}
+	return nil
+}
+
+// keysWithPrefix returns the keys starting with the given prefix, in order.
+// The caller must hold the lock.
+func (a *memoryKV) keysWithPrefix(prefix string) []string {
+	var keys []string
+	for key := range a.data {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,177 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisBackend is the name of the storage backend storing the database in a
+// server speaking the Redis protocol. The database is shared by all the
+// replicas of the controller.
+const RedisBackend = "redis"
+
+// redisKeyPrefix namespaces the keys of the database in the Redis server.
+const redisKeyPrefix = "image-reflector:"
+
+// redisTimeout is the time given to the Redis server to answer a command, for
+// the reconciliations not to hang on an unresponsive server. It's the read
+// and write timeout of the connections, unless the URL sets them.
+const redisTimeout = 10 * time.Second
+
+// redisGlobEscaper escapes the special characters of the patterns of the SCAN
+// command.
+var redisGlobEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`, "]", `\]`)
+
+func init() {
+	Register(RedisBackend, openRedis)
+}
+
+// openRedis connects to the Redis server at the URL of the options.
+func openRedis(opts Options) (Database, func() error, error) {
+	if opts.URL == "" {
+		return nil, nil, errors.New("the Redis backend requires a URL")
+	}
+	redisOpts, err := redis.ParseURL(opts.URL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid Redis URL: %w", err)
+	}
+	if redisOpts.ReadTimeout == 0 {
+		redisOpts.ReadTimeout = redisTimeout
+	}
+	if redisOpts.WriteTimeout == 0 {
+		redisOpts.WriteTimeout = redisTimeout
+	}
+	client := redis.NewClient(redisOpts)
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, nil, fmt.Errorf("failed to connect to Redis: %w", err)
+	}
+	return NewRedisDatabase(client), client.Close, nil
+}
+
+// NewRedisDatabase creates and returns a new database implementation using
+// the Redis server of the client for storing the image tags. The values are
+// the same as with Badger, and the keys are prefixed with redisKeyPrefix.
+func NewRedisDatabase(client redis.UniversalClient) *KVDatabase {
+	return NewKVDatabase(&redisKV{client: client})
+}
+
+// redisKV implements the KV interface on a Redis server. The lists are stored
+// as Redis lists. Every command is given redisTimeout to complete.
+type redisKV struct {
+	client redis.UniversalClient
+}
+
+// Get implements the KV interface.
+func (a *redisKV) Get(key string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	b, err := a.client.Get(ctx, redisKeyPrefix+key).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	return b, err
+}
+
+// Set implements the KV interface.
+func (a *redisKV) Set(key string, value []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	return a.client.Set(ctx, redisKeyPrefix+key, value, 0).Err()
+}
+
+// Delete implements the KV interface.
+func (a *redisKV) Delete(keys ...string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = redisKeyPrefix + key
+	}
+	return a.client.Del(ctx, prefixed...).Err()
+}
+
+// Scan implements the KV interface. The keys are iterated with the SCAN
+// command, each call given redisTimeout to complete.
+func (a *redisKV) Scan(prefix string, fn func(key string) error) error {
+	match := redisGlobEscaper.Replace(redisKeyPrefix+prefix) + "*"
+	var cursor uint64
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+		keys, next, err := a.client.Scan(ctx, cursor, match, 1000).Result()
+		cancel()
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if err := fn(strings.TrimPrefix(key, redisKeyPrefix)); err != nil {
+				return err
+			}
+		}
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// Append implements the KV interface.
+func (a *redisKV) Append(list string, value []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	return a.client.RPush(ctx, redisKeyPrefix+list, value).Err()
+}
+
+// List implements the KV interface.
+func (a *redisKV) List(list string) ([][]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	values, err := a.client.LRange(ctx, redisKeyPrefix+list, 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	b := make([][]byte, len(values))
+	for i, v := range values {
+		b[i] = []byte(v)
+	}
+	return b, nil
+}
+
+// Last implements the KV interface.
+func (a *redisKV) Last(list string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	b, err := a.client.LIndex(ctx, redisKeyPrefix+list, -1).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	return b, err
+}
+
+// DeleteList implements the KV interface.
+func (a *redisKV) DeleteList(list string) error {
+	return a.Delete(list)
+}
@@ -0,0 +1,139 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package database
+
+import (
+	"errors"
+	"fmt"
+)
+
+// SchemaVersion is the version of the layout of the data written by this
+// version of the controller. The databases written by the versions of the
+// controller predating schema versions have version 0.
+const SchemaVersion = 1
+
+// schemaPrefix and schemaRepo make the key of the schema of the database. It's
+// not the key of a repo.
+const (
+	schemaPrefix = "schema"
+	schemaRepo   = "version"
+)
+
+// ErrIncompatibleSchema is returned when opening a database written with a
+// schema version this version of the controller can't use, i.e. by a newer
+// version of the controller.
+var ErrIncompatibleSchema = errors.New("incompatible database schema")
+
+// Schema describes the layout of the data of a database.
+type Schema struct {
+	// Version is the version of the layout of the data.
+	Version int `json:"version"`
+	// Compatible is the oldest schema version whose readers and writers can
+	// use the data, for newer versions to remain usable after a downgrade
+	// when their changes are backward compatible.
+	Compatible int `json:"compatible"`
+}
+
+// Migration upgrades the data of a database to a schema version.
+type Migration struct {
+	// Version is the schema version of the data after the migration.
+	Version int
+	// Compatible is the oldest schema version able to use the data after the
+	// migration.
+	Compatible int
+	// Description explains the changes of the migration.
+	Description string
+	// Migrate upgrades the data written with the previous schema version. It
+	// must be idempotent, for an interrupted migration to be run again. It may
+	// be nil if the version doesn't change the existing data.
+	Migrate func(db Database) error
+}
+
+// migrations are the migrations up to SchemaVersion, in order.
+var migrations = []Migration{
+	{
+		Version: 1,
+		// The controllers predating schema versions read and write the same
+		// layout.
+		Compatible:  0,
+		Description: "record the schema version",
+	},
+}
+
+// Migrate upgrades the data of the database to SchemaVersion. An empty
+// database is only marked with the current schema. It returns an error
+// wrapping ErrIncompatibleSchema if the data can't be used by this version,
+// and leaves the data written with a newer but compatible schema untouched.
+func Migrate(db Database) error {
+	return migrate(db, migrations)
+}
+
+func migrate(db Database, migrations []Migration) error {
+	if len(migrations) == 0 {
+		return nil
+	}
+	latest := migrations[len(migrations)-1]
+	current := Schema{Version: latest.Version, Compatible: latest.Compatible}
+
+	s, err := db.Schema()
+	if err != nil {
+		return fmt.Errorf("failed to read database schema: %w", err)
+	}
+	if err := checkSchema(s, current.Version); err != nil {
+		return err
+	}
+	if s.Version >= current.Version {
+		return nil
+	}
+
+	if s.Version == 0 {
+		repos, err := db.Repositories()
+		if err != nil {
+			return fmt.Errorf("failed to list images in database: %w", err)
+		}
+		if len(repos) == 0 {
+			return db.SetSchema(current)
+		}
+	}
+
+	for _, m := range migrations {
+		if m.Version <= s.Version {
+			continue
+		}
+		if m.Migrate != nil {
+			if err := m.Migrate(db); err != nil {
+				return fmt.Errorf("failed to migrate database to schema version %d (%s): %w", m.Version, m.Description, err)
+			}
+		}
+		// Record every step, for an interrupted migration to resume after
+		// the last completed one.
+		if err := db.SetSchema(Schema{Version: m.Version, Compatible: m.Compatible}); err != nil {
+			return fmt.Errorf("failed to record database schema: %w", err)
+		}
+	}
+	return nil
+}
+
+// checkSchema returns an error wrapping ErrIncompatibleSchema if data with the
+// given schema can't be used with the supported schema version.
+func checkSchema(s Schema, supported int) error {
+	if s.Compatible > supported {
+		return fmt.Errorf("%w: the data has schema version %d, usable from version %d, and this version of the controller supports up to version %d",
+			ErrIncompatibleSchema, s.Version, s.Compatible, supported)
+	}
+	return nil
+}
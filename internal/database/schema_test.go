@@ -0,0 +1,213 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package database
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMigrationsUpToSchemaVersion(t *testing.T) {
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Fatalf("migration %d has version %d, want %d", i, m.Version, i+1)
+		}
+		if m.Compatible > m.Version {
+			t.Fatalf("migration %d is compatible from version %d, newer than itself", m.Version, m.Compatible)
+		}
+	}
+	if got := migrations[len(migrations)-1].Version; got != SchemaVersion {
+		t.Fatalf("last migration has version %d, want SchemaVersion %d", got, SchemaVersion)
+	}
+}
+
+// upperTags is a migration recording the tags in upper case.
+func upperTags(db Database) error {
+	repos, err := db.Repositories()
+	if err != nil {
+		return err
+	}
+	for _, repo := range repos {
+		tags, err := db.Tags(repo)
+		if err != nil {
+			return err
+		}
+		for i := range tags {
+			tags[i] = strings.ToUpper(tags[i])
+		}
+		if err := db.SetTags(repo, tags); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestMigrate(t *testing.T) {
+	failing := func(Database) error {
+		return errors.New("migration failed")
+	}
+
+	tests := []struct {
+		name       string
+		schema     *Schema
+		tags       []string
+		migrations []Migration
+		wantErr    error
+		wantSchema Schema
+		wantTags   []string
+	}{
+		{
+			name:       "marks empty database",
+			migrations: []Migration{{Version: 1}, {Version: 2, Compatible: 2, Migrate: upperTags}},
+			wantSchema: Schema{Version: 2, Compatible: 2},
+			wantTags:   []string{},
+		},
+		{
+			name:       "migrates database without schema",
+			tags:       []string{"v1"},
+			migrations: []Migration{{Version: 1}, {Version: 2, Compatible: 2, Migrate: upperTags}},
+			wantSchema: Schema{Version: 2, Compatible: 2},
+			wantTags:   []string{"V1"},
+		},
+		{
+			name:       "migrates from recorded version",
+			schema:     &Schema{Version: 1},
+			tags:       []string{"v1"},
+			migrations: []Migration{{Version: 1, Migrate: failing}, {Version: 2, Compatible: 2, Migrate: upperTags}},
+			wantSchema: Schema{Version: 2, Compatible: 2},
+			wantTags:   []string{"V1"},
+		},
+		{
+			name:       "current version",
+			schema:     &Schema{Version: 2, Compatible: 2},
+			tags:       []string{"v1"},
+			migrations: []Migration{{Version: 1}, {Version: 2, Compatible: 2, Migrate: upperTags}},
+			wantSchema: Schema{Version: 2, Compatible: 2},
+			wantTags:   []string{"v1"},
+		},
+		{
+			name:       "failed migration records the last completed version",
+			tags:       []string{"v1"},
+			migrations: []Migration{{Version: 1, Migrate: upperTags}, {Version: 2, Migrate: failing}},
+			wantErr:    errors.New("failed to migrate database to schema version 2"),
+			wantSchema: Schema{Version: 1},
+			wantTags:   []string{"V1"},
+		},
+		{
+			name:       "newer compatible version is left untouched",
+			schema:     &Schema{Version: 3, Compatible: 1},
+			tags:       []string{"v1"},
+			migrations: []Migration{{Version: 1}},
+			wantSchema: Schema{Version: 3, Compatible: 1},
+			wantTags:   []string{"v1"},
+		},
+		{
+			name:       "newer incompatible version",
+			schema:     &Schema{Version: 2, Compatible: 2},
+			tags:       []string{"v1"},
+			migrations: []Migration{{Version: 1}},
+			wantErr:    ErrIncompatibleSchema,
+			wantSchema: Schema{Version: 2, Compatible: 2},
+			wantTags:   []string{"v1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := NewMemoryDatabase()
+			if tt.schema != nil {
+				fatalIfError(t, db.SetSchema(*tt.schema))
+			}
+			if tt.tags != nil {
+				fatalIfError(t, db.SetTags(testRepo, tt.tags))
+			}
+
+			err := migrate(db, tt.migrations)
+			switch {
+			case tt.wantErr == nil && err != nil:
+				t.Fatalf("migrate() error = %v", err)
+			case tt.wantErr != nil && err == nil:
+				t.Fatalf("migrate() succeeded, want error %q", tt.wantErr)
+			case tt.wantErr != nil && !errors.Is(err, tt.wantErr) && !strings.Contains(err.Error(), tt.wantErr.Error()):
+				t.Fatalf("migrate() error = %v, want %q", err, tt.wantErr)
+			}
+
+			schema, err := db.Schema()
+			fatalIfError(t, err)
+			if schema != tt.wantSchema {
+				t.Errorf("Schema() after migrate got %#v, want %#v", schema, tt.wantSchema)
+			}
+			tags, err := db.Tags(testRepo)
+			fatalIfError(t, err)
+			if !reflect.DeepEqual(tt.wantTags, tags) {
+				t.Errorf("Tags() after migrate got %#v, want %#v", tags, tt.wantTags)
+			}
+		})
+	}
+}
+
+func TestImportIncompatibleSnapshot(t *testing.T) {
+	src := NewMemoryDatabase()
+	fatalIfError(t, src.SetSchema(Schema{Version: SchemaVersion + 1, Compatible: SchemaVersion + 1}))
+	fatalIfError(t, src.SetTags(testRepo, []string{"v1"}))
+	var buf bytes.Buffer
+	fatalIfError(t, Export(src, &buf))
+
+	dst := NewMemoryDatabase()
+	if err := Import(dst, &buf); !errors.Is(err, ErrIncompatibleSchema) {
+		t.Fatalf("Import() of a newer snapshot error = %v, want %v", err, ErrIncompatibleSchema)
+	}
+	repos, err := dst.Repositories()
+	fatalIfError(t, err)
+	if len(repos) != 0 {
+		t.Fatalf("Import() of a newer snapshot recorded %#v", repos)
+	}
+}
+
+func TestImportMigratesSnapshot(t *testing.T) {
+	// A snapshot taken before schema versions.
+	src := NewMemoryDatabase()
+	fatalIfError(t, src.SetTags(testRepo, []string{"v1"}))
+	fatalIfError(t, src.AppendPendingTags(testRepo, []string{"v2"}))
+	var buf bytes.Buffer
+	fatalIfError(t, Export(src, &buf))
+	s, err := readSnapshot(&buf)
+	fatalIfError(t, err)
+
+	dst := NewMemoryDatabase()
+	fatalIfError(t, dst.SetSchema(Schema{Version: 2, Compatible: 2}))
+	fatalIfError(t, importSnapshot(dst, s, []Migration{{Version: 1}, {Version: 2, Compatible: 2, Migrate: upperTags}}))
+
+	tags, err := dst.Tags(testRepo)
+	fatalIfError(t, err)
+	if want := []string{"V1"}; !reflect.DeepEqual(want, tags) {
+		t.Errorf("Tags() after Import got %#v, want %#v", tags, want)
+	}
+	pending, err := dst.PendingTags(testRepo)
+	fatalIfError(t, err)
+	if want := []string{"v2"}; !reflect.DeepEqual(want, pending) {
+		t.Errorf("PendingTags() after Import got %#v, want %#v", pending, want)
+	}
+	schema, err := dst.Schema()
+	fatalIfError(t, err)
+	if want := (Schema{Version: 2, Compatible: 2}); schema != want {
+		t.Errorf("Schema() after Import got %#v, want %#v", schema, want)
+	}
+}
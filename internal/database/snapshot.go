@@ -0,0 +1,200 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package database
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Snapshot holds the data of all the repos of a database, independently of the
+// backend.
+type Snapshot struct {
+	// Schema is the schema of the database the snapshot was taken from.
+	Schema       Schema                        `json:"schema"`
+	Repositories map[string]RepositorySnapshot `json:"repositories"`
+}
+
+// RepositorySnapshot holds the data recorded for a repo.
+type RepositorySnapshot struct {
+	Tags        []string             `json:"tags,omitempty"`
+	Digests     map[string]string    `json:"digests,omitempty"`
+	FirstSeen   map[string]time.Time `json:"firstSeen,omitempty"`
+	Created     map[string]time.Time `json:"created,omitempty"`
+	PendingTags []string             `json:"pendingTags,omitempty"`
+	ETag        string               `json:"etag,omitempty"`
+	TagsDigest  string               `json:"tagsDigest,omitempty"`
+	Platforms   map[string][]string  `json:"platforms,omitempty"`
+}
+
+// Export writes a snapshot of the data of all the repos of the database to w,
+// as gzip compressed JSON.
+func Export(db Database, w io.Writer) error {
+	s, err := takeSnapshot(db)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(w)
+	if err := json.NewEncoder(zw).Encode(s); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// Import records the data of the snapshot read from r, as written by Export,
+// in the database. The data of the repos of the snapshot is overwritten, and
+// their pending tags are appended as a single page. A snapshot taken from a
+// database with an older schema is migrated before it's recorded. It returns
+// an error wrapping ErrIncompatibleSchema if the snapshot was taken from a
+// database this version of the controller can't use.
+func Import(db Database, r io.Reader) error {
+	s, err := readSnapshot(r)
+	if err != nil {
+		return err
+	}
+	return importSnapshot(db, s, migrations)
+}
+
+// takeSnapshot reads the data of all the repos of the database.
+func takeSnapshot(db Database) (Snapshot, error) {
+	repos, err := db.Repositories()
+	if err != nil {
+		return Snapshot{}, err
+	}
+	schema, err := db.Schema()
+	if err != nil {
+		return Snapshot{}, err
+	}
+	s := Snapshot{Schema: schema, Repositories: map[string]RepositorySnapshot{}}
+	for _, repo := range repos {
+		var rs RepositorySnapshot
+		if rs.Tags, err = db.Tags(repo); err != nil {
+			return Snapshot{}, err
+		}
+		if rs.Digests, err = db.Digests(repo); err != nil {
+			return Snapshot{}, err
+		}
+		if rs.FirstSeen, err = db.FirstSeen(repo); err != nil {
+			return Snapshot{}, err
+		}
+		if rs.Created, err = db.Created(repo); err != nil {
+			return Snapshot{}, err
+		}
+		if rs.PendingTags, err = db.PendingTags(repo); err != nil {
+			return Snapshot{}, err
+		}
+		if rs.ETag, rs.TagsDigest, err = db.TagsValidator(repo); err != nil {
+			return Snapshot{}, err
+		}
+		if rs.Platforms, err = db.Platforms(repo); err != nil {
+			return Snapshot{}, err
+		}
+		s.Repositories[repo] = rs
+	}
+	return s, nil
+}
+
+// readSnapshot decodes the snapshot read from r, as written by Export.
+func readSnapshot(r io.Reader) (Snapshot, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("failed to decompress snapshot: %w", err)
+	}
+	defer zr.Close()
+	var s Snapshot
+	if err := json.NewDecoder(zr).Decode(&s); err != nil {
+		return Snapshot{}, fmt.Errorf("failed to decode snapshot: %w", err)
+	}
+	return s, nil
+}
+
+// importSnapshot records the data of the snapshot in the database, after
+// migrating it with the given migrations if it was taken from a database with
+// an older schema.
+func importSnapshot(db Database, s Snapshot, migrations []Migration) error {
+	latest := migrations[len(migrations)-1]
+	if err := checkSchema(s.Schema, latest.Version); err != nil {
+		return err
+	}
+	if s.Schema.Version < latest.Version {
+		// The snapshot is migrated in memory, for the database never to
+		// hold data with an older layout than its schema.
+		mem := NewMemoryDatabase()
+		if err := mem.SetSchema(s.Schema); err != nil {
+			return err
+		}
+		if err := writeSnapshot(mem, s); err != nil {
+			return err
+		}
+		if err := migrate(mem, migrations); err != nil {
+			return fmt.Errorf("failed to migrate snapshot: %w", err)
+		}
+		var err error
+		if s, err = takeSnapshot(mem); err != nil {
+			return err
+		}
+	}
+	return writeSnapshot(db, s)
+}
+
+// writeSnapshot records the data of the repos of the snapshot in the database.
+func writeSnapshot(db Database, s Snapshot) error {
+	for repo, rs := range s.Repositories {
+		if rs.Tags != nil {
+			if err := db.SetTags(repo, rs.Tags); err != nil {
+				return err
+			}
+		}
+		if rs.Digests != nil {
+			if err := db.SetDigests(repo, rs.Digests); err != nil {
+				return err
+			}
+		}
+		if rs.FirstSeen != nil {
+			if err := db.SetFirstSeen(repo, rs.FirstSeen); err != nil {
+				return err
+			}
+		}
+		if rs.Created != nil {
+			if err := db.SetCreated(repo, rs.Created); err != nil {
+				return err
+			}
+		}
+		if rs.ETag != "" || rs.TagsDigest != "" {
+			if err := db.SetTagsValidator(repo, rs.ETag, rs.TagsDigest); err != nil {
+				return err
+			}
+		}
+		if rs.Platforms != nil {
+			if err := db.SetPlatforms(repo, rs.Platforms); err != nil {
+				return err
+			}
+		}
+		if len(rs.PendingTags) > 0 {
+			if err := db.ClearPendingTags(repo); err != nil {
+				return err
+			}
+			if err := db.AppendPendingTags(repo, rs.PendingTags); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package database
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportImport(t *testing.T) {
+	src := NewMemoryDatabase()
+	tags := []string{"v0.0.1", "v0.0.2"}
+	digests := map[string]string{"v0.0.1": "sha256:aaaa", "v0.0.2": "sha256:bbbb"}
+	firstSeen := map[string]time.Time{"v0.0.1": time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)}
+	created := map[string]time.Time{"v0.0.2": time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)}
+	platforms := map[string][]string{"sha256:aaaa": {"linux/amd64"}}
+	fatalIfError(t, src.SetTags(testRepo, tags))
+	fatalIfError(t, src.SetDigests(testRepo, digests))
+	fatalIfError(t, src.SetFirstSeen(testRepo, firstSeen))
+	fatalIfError(t, src.SetCreated(testRepo, created))
+	fatalIfError(t, src.SetTagsValidator(testRepo, `"abc"`, "sha256:cccc"))
+	fatalIfError(t, src.SetPlatforms(testRepo, platforms))
+	fatalIfError(t, src.AppendPendingTags("localhost:5000/pending", []string{"v1"}))
+	fatalIfError(t, src.AppendPendingTags("localhost:5000/pending", []string{"v2"}))
+
+	var buf bytes.Buffer
+	fatalIfError(t, Export(src, &buf))
+
+	dst := NewMemoryDatabase()
+	fatalIfError(t, dst.SetTags(testRepo, []string{"outdated"}))
+	fatalIfError(t, Import(dst, &buf))
+
+	repos, err := dst.Repositories()
+	fatalIfError(t, err)
+	if want := []string{"localhost:5000/pending", testRepo}; !reflect.DeepEqual(want, repos) {
+		t.Fatalf("Repositories() after Import got %#v, want %#v", repos, want)
+	}
+	loadedTags, err := dst.Tags(testRepo)
+	fatalIfError(t, err)
+	if !reflect.DeepEqual(tags, loadedTags) {
+		t.Errorf("Tags() after Import got %#v, want %#v", loadedTags, tags)
+	}
+	loadedDigests, err := dst.Digests(testRepo)
+	fatalIfError(t, err)
+	if !reflect.DeepEqual(digests, loadedDigests) {
+		t.Errorf("Digests() after Import got %#v, want %#v", loadedDigests, digests)
+	}
+	loadedFirstSeen, err := dst.FirstSeen(testRepo)
+	fatalIfError(t, err)
+	if !reflect.DeepEqual(firstSeen, loadedFirstSeen) {
+		t.Errorf("FirstSeen() after Import got %#v, want %#v", loadedFirstSeen, firstSeen)
+	}
+	loadedCreated, err := dst.Created(testRepo)
+	fatalIfError(t, err)
+	if !reflect.DeepEqual(created, loadedCreated) {
+		t.Errorf("Created() after Import got %#v, want %#v", loadedCreated, created)
+	}
+	etag, digest, err := dst.TagsValidator(testRepo)
+	fatalIfError(t, err)
+	if etag != `"abc"` || digest != "sha256:cccc" {
+		t.Errorf("TagsValidator() after Import got (%q, %q)", etag, digest)
+	}
+	loadedPlatforms, err := dst.Platforms(testRepo)
+	fatalIfError(t, err)
+	if !reflect.DeepEqual(platforms, loadedPlatforms) {
+		t.Errorf("Platforms() after Import got %#v, want %#v", loadedPlatforms, platforms)
+	}
+	pending, err := dst.PendingTags("localhost:5000/pending")
+	fatalIfError(t, err)
+	if want := []string{"v1", "v2"}; !reflect.DeepEqual(want, pending) {
+		t.Errorf("PendingTags() after Import got %#v, want %#v", pending, want)
+	}
+}
+
+func TestImportInvalid(t *testing.T) {
+	if err := Import(NewMemoryDatabase(), strings.NewReader("not gzip")); err == nil {
+		t.Fatal("Import() of an invalid snapshot succeeded, want error")
+	}
+}
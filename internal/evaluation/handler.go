@@ -0,0 +1,209 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package evaluation serves the evaluation of ImagePolicy specs against
+// arbitrary tag lists, or the tags of a repository in the database, to debug
+// policies without creating objects.
+package evaluation
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-containerregistry/pkg/name"
+
+	imagev1 "github.com/fluxcd/image-reflector-controller/api/v1beta2"
+)
+
+// Path is the path of the evaluation endpoint.
+const Path = "/policy/evaluate"
+
+// maxPayloadSize is the maximum size of the accepted requests.
+const maxPayloadSize = 1 << 20
+
+// Database reads the tags of repositories.
+type Database interface {
+	Tags(repo string) ([]string, error)
+}
+
+// Evaluator evaluates the filters, policy and tie-breaker of an ImagePolicy
+// spec against the given tags, ordered by the times recorded for the given
+// canonical repository, if any. It returns an error if the spec is invalid,
+// otherwise the error of the policy is reported in the evaluation.
+type Evaluator interface {
+	EvaluatePolicy(spec imagev1.ImagePolicySpec, repo string, tags []string) (*Evaluation, error)
+}
+
+// Evaluation is the result of evaluating an ImagePolicy spec against a list of
+// tags.
+type Evaluation struct {
+	// Matched lists the tags passing the filters, in alphabetical order.
+	Matched []string `json:"matched"`
+	// Values holds the values extracted by the filters from the matched tags,
+	// by tag.
+	Values map[string]string `json:"values"`
+	// Ranked lists the tags ranked by the policy, from the latest on.
+	Ranked []string `json:"ranked"`
+	// Latest is the tag selected by the policy, if any.
+	Latest string `json:"latest,omitempty"`
+	// Skipped is the number of matched tags skipped by the policy because
+	// they could not be parsed.
+	Skipped int `json:"skipped,omitempty"`
+	// Error is the error of the policy when it selects no tag.
+	Error string `json:"error,omitempty"`
+}
+
+// Request is the body of an evaluation request.
+type Request struct {
+	// Spec is the ImagePolicy spec to evaluate. Only the filters, policy and
+	// tie-breaker are evaluated.
+	Spec imagev1.ImagePolicySpec `json:"spec"`
+	// Tags is the list of tags to evaluate the spec against.
+	Tags []string `json:"tags,omitempty"`
+	// Repository is the image repository, e.g. 'ghcr.io/org/app', whose tags
+	// recorded in the database the spec is evaluated against when no tags
+	// are given.
+	Repository string `json:"repository,omitempty"`
+}
+
+// Server is an HTTP server evaluating the requests posted as JSON to Path, and
+// responding with the JSON encoding of an Evaluation.
+type Server struct {
+	// Addr is the address the server binds to.
+	Addr string
+	// Token must be given by the requests in the Authorization header, as a
+	// bearer token. All the requests are rejected when it's empty.
+	Token     string
+	Database  Database
+	Evaluator Evaluator
+	Logger    logr.Logger
+}
+
+// Handler returns the HTTP handler of the server.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle(Path, http.HandlerFunc(s.evaluate))
+	return mux
+}
+
+// Start runs the server until the context is cancelled. It refuses to start
+// without token, for the tags of all the repositories not to be exposed.
+func (s *Server) Start(ctx context.Context) error {
+	if s.Token == "" {
+		return errors.New("the policy evaluation server requires a token")
+	}
+	srv := &http.Server{
+		Addr:              s.Addr,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		s.Logger.Info("starting policy evaluation server", "addr", s.Addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errCh <- err
+		}
+		close(errCh)
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return srv.Shutdown(shutdownCtx)
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable. The requests
+// are served by all the replicas, not only the leader, as they only read the
+// database.
+func (s *Server) NeedLeaderElection() bool {
+	return false
+}
+
+// verifyToken checks the Authorization header against the token. No request
+// is authorized without token.
+func (s *Server) verifyToken(header http.Header) bool {
+	if s.Token == "" {
+		return false
+	}
+	got := strings.TrimPrefix(header.Get("Authorization"), "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(got), []byte(s.Token)) == 1
+}
+
+func (s *Server) evaluate(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.verifyToken(req.Header) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(req.Body, maxPayloadSize))
+	if err != nil {
+		http.Error(w, "failed to read request", http.StatusBadRequest)
+		return
+	}
+	var r Request
+	if err := json.Unmarshal(body, &r); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	tags := r.Tags
+	var canonicalName string
+	if r.Repository != "" {
+		repo, err := name.NewRepository(r.Repository)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid repository '%s': %s", r.Repository, err), http.StatusBadRequest)
+			return
+		}
+		canonicalName = repo.String()
+	}
+	if len(tags) == 0 {
+		if canonicalName == "" {
+			http.Error(w, "invalid request: either tags or repository must be given", http.StatusBadRequest)
+			return
+		}
+		if tags, err = s.Database.Tags(canonicalName); err != nil {
+			http.Error(w, fmt.Sprintf("failed to read tags from database: %s", err), http.StatusInternalServerError)
+			return
+		}
+		if len(tags) == 0 {
+			http.Error(w, fmt.Sprintf("no tags in database for '%s'", canonicalName), http.StatusNotFound)
+			return
+		}
+	}
+
+	e, err := s.Evaluator.EvaluatePolicy(r.Spec, canonicalName, tags)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid policy: %s", err), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(e)
+}
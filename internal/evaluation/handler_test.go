@@ -0,0 +1,252 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package evaluation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	imagev1 "github.com/fluxcd/image-reflector-controller/api/v1beta2"
+)
+
+// testToken is the token of the servers of the tests.
+const testToken = "s3cr3t"
+
+type fakeDatabase map[string][]string
+
+func (db fakeDatabase) Tags(repo string) ([]string, error) {
+	return db[repo], nil
+}
+
+// fakeEvaluator selects the first of the given tags, and records the
+// repository and tags it was given.
+type fakeEvaluator struct {
+	repo string
+	tags []string
+}
+
+func (e *fakeEvaluator) EvaluatePolicy(spec imagev1.ImagePolicySpec, repo string, tags []string) (*Evaluation, error) {
+	if spec.Policy.SemVer == nil {
+		return nil, errors.New("invalid policy")
+	}
+	e.repo, e.tags = repo, tags
+	return &Evaluation{Ranked: tags, Latest: tags[0]}, nil
+}
+
+func TestServer(t *testing.T) {
+	db := fakeDatabase{
+		"index.docker.io/library/app": {"1.0.0", "1.1.0", "2.0.0-rc.1"},
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		header     map[string]string
+		body       string
+		noToken    bool
+		wantStatus int
+		wantRepo   string
+		wantTags   []string
+	}{
+		{
+			name:       "tags",
+			body:       `{"spec": {"policy": {"semver": {"range": "1.x"}}}, "tags": ["1.0.0", "1.2.0", "latest"]}`,
+			wantStatus: http.StatusOK,
+			wantTags:   []string{"1.0.0", "1.2.0", "latest"},
+		},
+		{
+			name:       "repository",
+			body:       `{"spec": {"policy": {"semver": {"range": ">=1.0.0-0"}}}, "repository": "app"}`,
+			wantStatus: http.StatusOK,
+			wantRepo:   "index.docker.io/library/app",
+			wantTags:   []string{"1.0.0", "1.1.0", "2.0.0-rc.1"},
+		},
+		{
+			name:       "tags and repository",
+			body:       `{"spec": {"policy": {"semver": {"range": "1.x"}}}, "tags": ["1.0.0"], "repository": "app"}`,
+			wantStatus: http.StatusOK,
+			wantRepo:   "index.docker.io/library/app",
+			wantTags:   []string{"1.0.0"},
+		},
+		{
+			name:       "unknown repository",
+			body:       `{"spec": {"policy": {"semver": {"range": "1.x"}}}, "repository": "other"}`,
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "no tags nor repository",
+			body:       `{"spec": {"policy": {"semver": {"range": "1.x"}}}}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid policy",
+			body:       `{"spec": {"policy": {}}, "tags": ["1.0.0"]}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "wrong method",
+			method:     http.MethodGet,
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			name:       "without token",
+			header:     map[string]string{"Authorization": ""},
+			body:       `{"spec": {"policy": {"semver": {"range": "1.x"}}}, "tags": ["1.0.0"]}`,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "with wrong token",
+			header:     map[string]string{"Authorization": "Bearer wrong"},
+			body:       `{"spec": {"policy": {"semver": {"range": "1.x"}}}, "tags": ["1.0.0"]}`,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "no token configured",
+			header:     map[string]string{"Authorization": "Bearer "},
+			body:       `{"spec": {"policy": {"semver": {"range": "1.x"}}}, "tags": ["1.0.0"]}`,
+			noToken:    true,
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			evaluator := &fakeEvaluator{}
+			s := &Server{
+				Token:     testToken,
+				Database:  db,
+				Evaluator: evaluator,
+			}
+			if tt.noToken {
+				s.Token = ""
+			}
+
+			method := tt.method
+			if method == "" {
+				method = http.MethodPost
+			}
+			req := httptest.NewRequest(method, Path, strings.NewReader(tt.body))
+			req.Header.Set("Authorization", "Bearer "+testToken)
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			s.Handler().ServeHTTP(rec, req)
+
+			g.Expect(rec.Code).To(Equal(tt.wantStatus), rec.Body.String())
+			if tt.wantStatus != http.StatusOK {
+				g.Expect(evaluator.tags).To(BeNil())
+				return
+			}
+			g.Expect(evaluator.repo).To(Equal(tt.wantRepo))
+			g.Expect(evaluator.tags).To(Equal(tt.wantTags))
+			var e Evaluation
+			g.Expect(json.Unmarshal(rec.Body.Bytes(), &e)).To(Succeed())
+			g.Expect(e.Latest).To(Equal(tt.wantTags[0]))
+		})
+	}
+}
+
+// unavailableLock is a leader election lock which can't be acquired, for the
+// manager to stay a standby replica.
+type unavailableLock struct{}
+
+func (unavailableLock) Get(context.Context) (*resourcelock.LeaderElectionRecord, []byte, error) {
+	return nil, nil, errors.New("lock unavailable")
+}
+
+func (unavailableLock) Create(context.Context, resourcelock.LeaderElectionRecord) error {
+	return errors.New("lock unavailable")
+}
+
+func (unavailableLock) Update(context.Context, resourcelock.LeaderElectionRecord) error {
+	return errors.New("lock unavailable")
+}
+
+func (unavailableLock) RecordEvent(string) {}
+
+func (unavailableLock) Identity() string { return "standby" }
+
+func (unavailableLock) Describe() string { return "unavailable" }
+
+func TestServer_standby(t *testing.T) {
+	g := NewWithT(t)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	g.Expect(err).ToNot(HaveOccurred())
+	addr := l.Addr().String()
+	g.Expect(l.Close()).To(Succeed())
+
+	mgr, err := ctrl.NewManager(&rest.Config{Host: "http://127.0.0.1:1"}, ctrl.Options{
+		LeaderElection:                      true,
+		LeaderElectionID:                    "test",
+		LeaderElectionResourceLockInterface: unavailableLock{},
+		MetricsBindAddress:                  "0",
+		MapperProvider: func(*rest.Config, *http.Client) (meta.RESTMapper, error) {
+			return meta.NewDefaultRESTMapper(nil), nil
+		},
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(mgr.Add(&Server{
+		Addr:      addr,
+		Token:     testToken,
+		Database:  fakeDatabase{"index.docker.io/library/app": {"1.0.0"}},
+		Evaluator: &fakeEvaluator{},
+	})).To(Succeed())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- mgr.Start(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	// The replica never becomes the leader, but serves the requests.
+	body := `{"spec": {"policy": {"semver": {"range": "1.x"}}}, "repository": "app"}`
+	g.Eventually(func() (int, error) {
+		req, err := http.NewRequest(http.MethodPost, "http://"+addr+Path, strings.NewReader(body))
+		if err != nil {
+			return 0, err
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return 0, err
+		}
+		resp.Body.Close()
+		return resp.StatusCode, nil
+	}, 10*time.Second, 100*time.Millisecond).Should(Equal(http.StatusOK))
+	g.Expect(mgr.Elected()).ToNot(BeClosed())
+}
@@ -19,6 +19,7 @@ package policy
 import (
 	"fmt"
 	"sort"
+	"strings"
 )
 
 const (
@@ -31,6 +32,9 @@ const (
 // Alphabetical representes a alphabetical ordering policy
 type Alphabetical struct {
 	Order string
+	// Natural compares the runs of digits in the versions numerically, e.g.
+	// so that 'build-10' is after 'build-9'.
+	Natural bool
 }
 
 // NewAlphabetical constructs a Alphabetical object validating the provided
@@ -52,15 +56,76 @@ func NewAlphabetical(order string) (*Alphabetical, error) {
 
 // Latest returns latest version from a provided list of strings
 func (p *Alphabetical) Latest(versions []string) (string, error) {
+	ranked, err := p.Rank(versions)
+	if err != nil {
+		return "", err
+	}
+	return ranked[0], nil
+}
+
+// Rank returns a provided list of strings ordered from the latest to the
+// oldest version
+func (p *Alphabetical) Rank(versions []string) ([]string, error) {
 	if len(versions) == 0 {
-		return "", fmt.Errorf("version list argument cannot be empty")
+		return nil, fmt.Errorf("version list argument cannot be empty")
 	}
 
-	var sorted sort.StringSlice = versions
+	var sorted sort.StringSlice = append([]string(nil), versions...)
+	if p.Natural {
+		sort.Slice(sorted, func(i, j int) bool {
+			if p.Order == AlphabeticalOrderDesc {
+				return naturalLess(sorted[i], sorted[j])
+			}
+			return naturalLess(sorted[j], sorted[i])
+		})
+		return sorted, nil
+	}
 	if p.Order == AlphabeticalOrderDesc {
 		sort.Sort(sorted)
 	} else {
 		sort.Sort(sort.Reverse(sorted))
 	}
-	return sorted[0], nil
+	return sorted, nil
+}
+
+// naturalLess returns whether a is before b in natural order, comparing the
+// runs of digits numerically and the rest bytewise. Of numerically equal
+// strings, e.g. 'v01' and 'v1', the one before in bytewise order is first.
+func naturalLess(a, b string) bool {
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		if isDigit(a[i]) && isDigit(b[j]) {
+			si, sj := i, j
+			for i < len(a) && isDigit(a[i]) {
+				i++
+			}
+			for j < len(b) && isDigit(b[j]) {
+				j++
+			}
+			// Compare the runs of digits without leading zeros by length,
+			// then digit by digit, to support numbers of any size.
+			na := strings.TrimLeft(a[si:i], "0")
+			nb := strings.TrimLeft(b[sj:j], "0")
+			if len(na) != len(nb) {
+				return len(na) < len(nb)
+			}
+			if na != nb {
+				return na < nb
+			}
+			continue
+		}
+		if a[i] != b[j] {
+			return a[i] < b[j]
+		}
+		i++
+		j++
+	}
+	if len(a)-i != len(b)-j {
+		return len(a)-i < len(b)-j
+	}
+	return a < b
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
 }
@@ -17,6 +17,7 @@ limitations under the License.
 package policy
 
 import (
+	"reflect"
 	"testing"
 )
 
@@ -142,3 +143,75 @@ func TestAlphabetical_Latest(t *testing.T) {
 		})
 	}
 }
+
+func TestAlphabetical_Rank(t *testing.T) {
+	versions := []string{"xenial", "yakkety", "zesty", "artful", "bionic"}
+	for order, expected := range map[string][]string{
+		AlphabeticalOrderAsc:  {"zesty", "yakkety", "xenial", "bionic", "artful"},
+		AlphabeticalOrderDesc: {"artful", "bionic", "xenial", "yakkety", "zesty"},
+	} {
+		policy, err := NewAlphabetical(order)
+		if err != nil {
+			t.Fatalf("returned unexpected error: %s", err)
+		}
+		ranked, err := policy.Rank(versions)
+		if err != nil {
+			t.Fatalf("returned unexpected error: %s", err)
+		}
+		if !reflect.DeepEqual(ranked, expected) {
+			t.Errorf("incorrect ranked versions returned for order %s, got %v, expected %v", order, ranked, expected)
+		}
+	}
+	if versions[0] != "xenial" {
+		t.Errorf("Rank modified the provided list: %v", versions)
+	}
+}
+
+func TestAlphabetical_RankNatural(t *testing.T) {
+	cases := []struct {
+		label          string
+		order          string
+		versions       []string
+		expectedRanked []string
+	}{
+		{
+			label:          "With build numbers",
+			versions:       []string{"build-9", "build-10", "build-1", "build-100"},
+			expectedRanked: []string{"build-100", "build-10", "build-9", "build-1"},
+		},
+		{
+			label:          "With build numbers descending",
+			order:          AlphabeticalOrderDesc,
+			versions:       []string{"build-9", "build-10", "build-1", "build-100"},
+			expectedRanked: []string{"build-1", "build-9", "build-10", "build-100"},
+		},
+		{
+			label:          "With mixed segments",
+			versions:       []string{"v1.10.0-rc2", "v1.9.0", "v1.10.0-rc10", "v1.10.0", "v1.10.0-rc1"},
+			expectedRanked: []string{"v1.10.0-rc10", "v1.10.0-rc2", "v1.10.0-rc1", "v1.10.0", "v1.9.0"},
+		},
+		{
+			label:          "With leading zeros and big numbers",
+			versions:       []string{"r007", "r7", "r10", "r99999999999999999999", "r100000000000000000000"},
+			expectedRanked: []string{"r100000000000000000000", "r99999999999999999999", "r10", "r7", "r007"},
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.label, func(t *testing.T) {
+			policy, err := NewAlphabetical(tt.order)
+			if err != nil {
+				t.Fatalf("returned unexpected error: %s", err)
+			}
+			policy.Natural = true
+
+			ranked, err := policy.Rank(shuffle(append([]string(nil), tt.versions...)))
+			if err != nil {
+				t.Fatalf("returned unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(ranked, tt.expectedRanked) {
+				t.Errorf("incorrect ranked versions returned, got %v, expected %v", ranked, tt.expectedRanked)
+			}
+		})
+	}
+}
@@ -0,0 +1,257 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The segments of calendar versions, see https://calver.org.
+const (
+	calVerFullYear   = "YYYY"
+	calVerShortYear  = "YY"
+	calVerPaddedYear = "0Y"
+	calVerMonth      = "MM"
+	calVerPadMonth   = "0M"
+	calVerWeek       = "WW"
+	calVerPadWeek    = "0W"
+	calVerDay        = "DD"
+	calVerPadDay     = "0D"
+	calVerMajor      = "MAJOR"
+	calVerMinor      = "MINOR"
+	calVerMicro      = "MICRO"
+)
+
+// calVerSegments gives the expression matching each segment, longest first
+// for the segments to be tokenized greedily.
+var calVerSegments = []struct {
+	name string
+	expr string
+}{
+	{calVerMajor, `[0-9]+`},
+	{calVerMinor, `[0-9]+`},
+	{calVerMicro, `[0-9]+`},
+	{calVerFullYear, `[0-9]{4}`},
+	{calVerShortYear, `[1-9][0-9]{0,2}|0`},
+	{calVerPaddedYear, `[0-9]{2,3}`},
+	{calVerMonth, `1[0-2]|[1-9]`},
+	{calVerPadMonth, `0[1-9]|1[0-2]`},
+	{calVerWeek, `5[0-3]|[1-4][0-9]|[1-9]`},
+	{calVerPadWeek, `5[0-3]|[1-4][0-9]|0[1-9]`},
+	{calVerDay, `3[01]|[12][0-9]|[1-9]`},
+	{calVerPadDay, `3[01]|[12][0-9]|0[1-9]`},
+}
+
+// calVerKey holds the parsed segments of a calendar version in the order they
+// are compared.
+type calVerKey struct {
+	date                time.Time
+	major, minor, micro uint64
+}
+
+func (k calVerKey) greaterThan(o calVerKey) bool {
+	switch {
+	case !k.date.Equal(o.date):
+		return k.date.After(o.date)
+	case k.major != o.major:
+		return k.major > o.major
+	case k.minor != o.minor:
+		return k.minor > o.minor
+	default:
+		return k.micro > o.micro
+	}
+}
+
+// CalVer represents a calendar versioning policy
+type CalVer struct {
+	Format string
+	// MaxAge excludes the versions whose date is older, if not zero. The date
+	// of a version is the first day of the period it denotes.
+	MaxAge time.Duration
+
+	regexp   *regexp.Regexp
+	segments []string
+	now      func() time.Time
+}
+
+// NewCalVer constructs a CalVer object validating the provided format
+func NewCalVer(format string, maxAge time.Duration) (*CalVer, error) {
+	if maxAge < 0 {
+		return nil, fmt.Errorf("invalid maximum age '%s', must not be negative", maxAge)
+	}
+
+	var expr strings.Builder
+	var segments []string
+	seen := map[string]bool{}
+	expr.WriteString("^")
+	for rest := format; rest != ""; {
+		matched := false
+		for _, s := range calVerSegments {
+			if strings.HasPrefix(rest, s.name) {
+				if seen[s.name] {
+					return nil, fmt.Errorf("invalid format '%s': duplicate segment %s", format, s.name)
+				}
+				seen[s.name] = true
+				segments = append(segments, s.name)
+				expr.WriteString("(" + s.expr + ")")
+				rest = rest[len(s.name):]
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			expr.WriteString(regexp.QuoteMeta(rest[:1]))
+			rest = rest[1:]
+		}
+	}
+	expr.WriteString("$")
+
+	count := func(names ...string) int {
+		n := 0
+		for _, name := range names {
+			if seen[name] {
+				n++
+			}
+		}
+		return n
+	}
+	years := count(calVerFullYear, calVerShortYear, calVerPaddedYear)
+	months := count(calVerMonth, calVerPadMonth)
+	weeks := count(calVerWeek, calVerPadWeek)
+	days := count(calVerDay, calVerPadDay)
+	switch {
+	case years != 1:
+		return nil, fmt.Errorf("invalid format '%s': must have exactly one year segment", format)
+	case months > 1 || weeks > 1 || days > 1:
+		return nil, fmt.Errorf("invalid format '%s': duplicate date segment", format)
+	case weeks > 0 && months+days > 0:
+		return nil, fmt.Errorf("invalid format '%s': week segment cannot be combined with month or day segments", format)
+	case days > 0 && months == 0:
+		return nil, fmt.Errorf("invalid format '%s': day segment requires a month segment", format)
+	}
+
+	return &CalVer{
+		Format:   format,
+		MaxAge:   maxAge,
+		regexp:   regexp.MustCompile(expr.String()),
+		segments: segments,
+		now:      time.Now,
+	}, nil
+}
+
+// Latest returns latest version from a provided list of strings
+func (p *CalVer) Latest(versions []string) (string, error) {
+	ranked, err := p.Rank(versions)
+	if err != nil {
+		return "", err
+	}
+	return ranked[0], nil
+}
+
+// Rank returns the versions of a provided list of strings in the format and
+// not older than the maximum age, from the latest to the oldest
+func (p *CalVer) Rank(versions []string) ([]string, error) {
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("version list argument cannot be empty")
+	}
+
+	type calVer struct {
+		version string
+		key     calVerKey
+	}
+	var candidates []calVer
+	for _, version := range versions {
+		key, ok := p.parse(version)
+		if !ok {
+			continue
+		}
+		if p.MaxAge > 0 && p.now().Sub(key.date) > p.MaxAge {
+			continue
+		}
+		candidates = append(candidates, calVer{version: version, key: key})
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("unable to determine latest version from provided list")
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].key.greaterThan(candidates[j].key)
+	})
+	ranked := make([]string, len(candidates))
+	for i, c := range candidates {
+		ranked[i] = c.version
+	}
+	return ranked, nil
+}
+
+// Valid returns whether the given version is in the format of the policy and
+// denotes a valid date.
+func (p *CalVer) Valid(version string) bool {
+	_, ok := p.parse(version)
+	return ok
+}
+
+// parse returns the key of the given version, and whether it is in the format
+// of the policy and denotes a valid date.
+func (p *CalVer) parse(version string) (calVerKey, bool) {
+	var key calVerKey
+	submatches := p.regexp.FindStringSubmatch(version)
+	if submatches == nil {
+		return key, false
+	}
+
+	year, month, week, day := 0, 1, 0, 1
+	for i, segment := range p.segments {
+		n, err := strconv.ParseUint(submatches[i+1], 10, 64)
+		if err != nil {
+			return key, false
+		}
+		switch segment {
+		case calVerFullYear:
+			year = int(n)
+		case calVerShortYear, calVerPaddedYear:
+			year = 2000 + int(n)
+		case calVerMonth, calVerPadMonth:
+			month = int(n)
+		case calVerWeek, calVerPadWeek:
+			week = int(n)
+		case calVerDay, calVerPadDay:
+			day = int(n)
+		case calVerMajor:
+			key.major = n
+		case calVerMinor:
+			key.minor = n
+		case calVerMicro:
+			key.micro = n
+		}
+	}
+
+	key.date = time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	// Reject dates normalized by time.Date, e.g. February 30th.
+	if key.date.Day() != day {
+		return key, false
+	}
+	if week > 0 {
+		key.date = key.date.AddDate(0, 0, (week-1)*7)
+	}
+	return key, true
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNewCalVer(t *testing.T) {
+	cases := []struct {
+		label     string
+		formats   []string
+		maxAge    time.Duration
+		expectErr bool
+	}{
+		{
+			label:   "With valid format",
+			formats: []string{"YYYY.0M.MICRO", "YY.0M", "YYYY-0M-0D", "0Y.WW", "YYYY.MAJOR.MINOR.MICRO", "release-YYYYMMDD"},
+		},
+		{
+			label:     "With invalid format",
+			formats:   []string{"", "MAJOR.MINOR", "YYYY.YY", "YYYY.MM.0M", "YYYY.WW.DD", "YYYY.DD", "YYYY.MICRO.MICRO"},
+			expectErr: true,
+		},
+		{
+			label:     "With negative maximum age",
+			formats:   []string{"YYYY.0M"},
+			maxAge:    -time.Hour,
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range cases {
+		for _, f := range tt.formats {
+			t.Run(tt.label, func(t *testing.T) {
+				_, err := NewCalVer(f, tt.maxAge)
+				if tt.expectErr && err == nil {
+					t.Fatalf("expecting error, got nil for format: '%s'", f)
+				}
+				if !tt.expectErr && err != nil {
+					t.Fatalf("returned unexpected error: %s", err)
+				}
+			})
+		}
+	}
+}
+
+func TestCalVer_Rank(t *testing.T) {
+	now := time.Date(2024, time.April, 15, 12, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		label          string
+		format         string
+		maxAge         time.Duration
+		versions       []string
+		expectedRanked []string
+		expectErr      bool
+	}{
+		{
+			label:          "With year, month and micro",
+			format:         "YYYY.0M.MICRO",
+			versions:       shuffle([]string{"2024.03.1", "2024.03.10", "2024.03.2", "2023.12.5", "2024.3.1", "v2024.04.0", "2024.04.0"}),
+			expectedRanked: []string{"2024.04.0", "2024.03.10", "2024.03.2", "2024.03.1", "2023.12.5"},
+		},
+		{
+			label:          "With short year and month",
+			format:         "YY.0M",
+			versions:       shuffle([]string{"24.04", "23.10", "22.04", "2024.04", "24.4"}),
+			expectedRanked: []string{"24.04", "23.10", "22.04"},
+		},
+		{
+			label:          "With dates",
+			format:         "YYYY-0M-0D",
+			versions:       shuffle([]string{"2023-11-05", "2023-02-30", "2024-01-31", "2023-11-15"}),
+			expectedRanked: []string{"2024-01-31", "2023-11-15", "2023-11-05"},
+		},
+		{
+			label:          "With weeks",
+			format:         "YYYY.WW",
+			versions:       shuffle([]string{"2024.1", "2023.52", "2024.10", "2024.2"}),
+			expectedRanked: []string{"2024.10", "2024.2", "2024.1", "2023.52"},
+		},
+		{
+			label:          "With maximum age",
+			format:         "YYYY-0M-0D",
+			maxAge:         90 * 24 * time.Hour,
+			versions:       shuffle([]string{"2023-11-05", "2024-01-31", "2024-01-16", "2024-01-17", "2024-04-01"}),
+			expectedRanked: []string{"2024-04-01", "2024-01-31", "2024-01-17"},
+		},
+		{
+			label:     "With all versions too old",
+			format:    "YY.0M",
+			maxAge:    24 * time.Hour,
+			versions:  []string{"24.03", "23.10"},
+			expectErr: true,
+		},
+		{
+			label:     "With no version in format",
+			format:    "YYYY.0M",
+			versions:  []string{"1.0.0", "latest"},
+			expectErr: true,
+		},
+		{
+			label:     "Empty version list",
+			format:    "YYYY.0M",
+			versions:  []string{},
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.label, func(t *testing.T) {
+			policy, err := NewCalVer(tt.format, tt.maxAge)
+			if err != nil {
+				t.Fatalf("returned unexpected error: %s", err)
+			}
+			policy.now = func() time.Time { return now }
+
+			ranked, err := policy.Rank(tt.versions)
+			if tt.expectErr && err == nil {
+				t.Fatalf("expecting error, got nil")
+			}
+			if !tt.expectErr && err != nil {
+				t.Fatalf("returned unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(ranked, tt.expectedRanked) {
+				t.Errorf("incorrect ranked versions returned, got %v, expected %v", ranked, tt.expectedRanked)
+			}
+
+			latest, err := policy.Latest(tt.versions)
+			if !tt.expectErr && latest != tt.expectedRanked[0] {
+				t.Errorf("incorrect computed version returned, got '%s', expected '%s'", latest, tt.expectedRanked[0])
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Composite represents a policy ranking tags by the values extracted from them
+// by a chain of filters, and ordering the tags with equal values by a
+// tie-breaker policy
+type Composite struct {
+	// Filters are applied in order, each to the values extracted by the
+	// previous one.
+	Filters []*RegexFilter
+	// Primary ranks the values extracted by the filters.
+	Primary Policer
+	// TieBreakerFilter extracts the values ranked by the tie-breaker from the
+	// original tags. Tags not passing the filter rank last among equals.
+	TieBreakerFilter *RegexFilter
+	// TieBreaker ranks the tags with equal values for the primary policy.
+	// Without a tie-breaker, they are ordered alphabetically.
+	TieBreaker Policer
+}
+
+// Latest returns latest version from a provided list of strings
+func (p *Composite) Latest(versions []string) (string, error) {
+	ranked, err := p.Rank(versions)
+	if err != nil {
+		return "", err
+	}
+	return ranked[0], nil
+}
+
+// Rank returns the versions of a provided list of strings passing the filters,
+// ordered from the latest to the oldest
+func (p *Composite) Rank(versions []string) ([]string, error) {
+	values := p.Values(versions)
+	ranked, err := rankBy(p.Primary, values)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]string, 0, len(values))
+	for _, equals := range ranked {
+		if len(equals) > 1 && p.TieBreaker != nil {
+			if equals, err = p.breakTie(equals); err != nil {
+				return nil, err
+			}
+		}
+		result = append(result, equals...)
+	}
+	return result, nil
+}
+
+// Skipped returns the number of the given versions passing the filters whose
+// values cannot be parsed by the primary policy, and are skipped by it.
+func (p *Composite) Skipped(versions []string) int {
+	validator, ok := p.Primary.(Validator)
+	if !ok {
+		return 0
+	}
+	skipped := 0
+	for _, value := range p.Values(versions) {
+		if !validator.Valid(value) {
+			skipped++
+		}
+	}
+	return skipped
+}
+
+// Values returns the values extracted by the filters from the given versions
+// passing them, by version.
+func (p *Composite) Values(versions []string) map[string]string {
+	values := map[string]string{}
+	for _, version := range versions {
+		values[version] = version
+	}
+	for _, f := range p.Filters {
+		for version, value := range values {
+			v, ok := f.Extract(value)
+			if !ok {
+				delete(values, version)
+				continue
+			}
+			values[version] = v
+		}
+	}
+	return values
+}
+
+// breakTie orders the given versions by the values extracted by the
+// tie-breaker filter, with the tie-breaker policy.
+func (p *Composite) breakTie(versions []string) ([]string, error) {
+	values := map[string]string{}
+	var rest []string
+	for _, version := range versions {
+		value := version
+		if p.TieBreakerFilter != nil {
+			v, ok := p.TieBreakerFilter.Extract(version)
+			if !ok {
+				rest = append(rest, version)
+				continue
+			}
+			value = v
+		}
+		values[version] = value
+	}
+
+	var result []string
+	if len(values) > 0 {
+		ranked, err := rankBy(p.TieBreaker, values)
+		if err != nil {
+			return nil, fmt.Errorf("failed to break tie: %w", err)
+		}
+		for _, equals := range ranked {
+			result = append(result, equals...)
+		}
+		// Versions whose values are not ranked by the tie-breaker, e.g. out
+		// of the range of a semver policy, rank last among equals.
+		seen := map[string]bool{}
+		for _, version := range result {
+			seen[version] = true
+		}
+		for version := range values {
+			if !seen[version] {
+				rest = append(rest, version)
+			}
+		}
+	}
+	sortDesc(rest)
+	return append(result, rest...), nil
+}
+
+// rankBy ranks the distinct values of the given versions with the given
+// policy, and returns the versions grouped by value in the order of their
+// values. The versions with equal values are ordered alphabetically.
+func rankBy(policer Policer, values map[string]string) ([][]string, error) {
+	byValue := map[string][]string{}
+	for version, value := range values {
+		byValue[value] = append(byValue[value], version)
+	}
+	distinct := make([]string, 0, len(byValue))
+	for value := range byValue {
+		distinct = append(distinct, value)
+	}
+	sort.Strings(distinct)
+
+	// Time based policies order the values by the times of their versions.
+	if newest, ok := policer.(*Newest); ok {
+		times := map[string]time.Time{}
+		for value, versions := range byValue {
+			for _, version := range versions {
+				t, ok := newest.Times[version]
+				if !ok {
+					continue
+				}
+				if vt, ok := times[value]; !ok || t.After(vt) {
+					times[value] = t
+				}
+			}
+		}
+		policer = &Newest{Source: newest.Source, Times: times}
+	}
+
+	ranked, err := policer.Rank(distinct)
+	if err != nil {
+		return nil, err
+	}
+	result := make([][]string, len(ranked))
+	for i, value := range ranked {
+		equals := byValue[value]
+		sortDesc(equals)
+		result[i] = equals
+	}
+	return result, nil
+}
+
+// sortDesc sorts the given strings in descending alphabetical order.
+func sortDesc(list []string) {
+	sort.Sort(sort.Reverse(sort.StringSlice(list)))
+}
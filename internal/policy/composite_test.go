@@ -0,0 +1,174 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestComposite_Rank(t *testing.T) {
+	base := time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		label          string
+		policy         *Composite
+		versions       []string
+		expectedRanked []string
+		expectErr      bool
+	}{
+		{
+			label:          "Without filters",
+			policy:         &Composite{Primary: mustSemVer(">=1.0.0")},
+			versions:       []string{"1.0.0", "2.0.0", "0.1.0", "1.1.0"},
+			expectedRanked: []string{"2.0.0", "1.1.0", "1.0.0"},
+		},
+		{
+			label: "With filter chain",
+			policy: &Composite{
+				Filters: []*RegexFilter{
+					mustFilter(`-rc`, "", true),
+					mustFilter(`^v(?P<version>.*)-(?P<build>[0-9]+)$`, "$version", false),
+				},
+				Primary: mustSemVer(">=2.0"),
+			},
+			versions:       []string{"v1.0.0-1", "v2.0.0-2", "v2.1.0-rc.1-3", "v2.0.1-4", "2.2.0"},
+			expectedRanked: []string{"v2.0.1-4", "v2.0.0-2"},
+		},
+		{
+			label: "With ties ordered alphabetically",
+			policy: &Composite{
+				Filters: []*RegexFilter{mustFilter(`^(?P<version>[0-9.]+)-`, "$version", false)},
+				Primary: mustSemVer(">=2.0"),
+			},
+			versions:       []string{"2.0.0-10", "2.0.0-9", "1.0.0-1", "2.1.0-1"},
+			expectedRanked: []string{"2.1.0-1", "2.0.0-9", "2.0.0-10"},
+		},
+		{
+			label: "With tie-breaker",
+			policy: &Composite{
+				Filters:          []*RegexFilter{mustFilter(`^(?P<version>[0-9.]+)-`, "$version", false)},
+				Primary:          mustSemVer(">=2.0"),
+				TieBreakerFilter: mustFilter(`-(?P<ts>[0-9]+)$`, "$ts", false),
+				TieBreaker:       &Numerical{Order: NumericalOrderAsc},
+			},
+			versions:       []string{"2.0.0-10", "2.0.0-9", "1.0.0-1", "2.1.0-1", "2.0.0-x", "2.0.0-11"},
+			expectedRanked: []string{"2.1.0-1", "2.0.0-11", "2.0.0-10", "2.0.0-9", "2.0.0-x"},
+		},
+		{
+			label: "With invalid tie-breaker values",
+			policy: &Composite{
+				Filters:    []*RegexFilter{mustFilter(`^(?P<version>[0-9.]+)-`, "$version", false)},
+				Primary:    mustSemVer(">=2.0"),
+				TieBreaker: &Numerical{Order: NumericalOrderAsc},
+			},
+			versions:  []string{"2.0.0-10", "2.0.0-9"},
+			expectErr: true,
+		},
+		{
+			label: "With newest primary policy",
+			policy: &Composite{
+				Filters: []*RegexFilter{mustFilter(`^main-(?P<sha>[a-f0-9]+)$`, "$sha", false)},
+				Primary: &Newest{Times: map[string]time.Time{
+					"main-abc": base,
+					"main-def": base.Add(time.Hour),
+					"dev-fff":  base.Add(2 * time.Hour),
+				}},
+			},
+			versions:       []string{"main-abc", "main-def", "dev-fff", "main-aaa"},
+			expectedRanked: []string{"main-def", "main-abc"},
+		},
+		{
+			label: "With newest tie-breaker",
+			policy: &Composite{
+				Filters: []*RegexFilter{mustFilter(`^(?P<version>[0-9.]+)-`, "$version", false)},
+				Primary: mustSemVer(">=1.0"),
+				TieBreaker: &Newest{Times: map[string]time.Time{
+					"1.0.0-a": base.Add(time.Hour),
+					"1.0.0-b": base,
+				}},
+			},
+			versions:       []string{"1.0.0-a", "1.0.0-b", "1.0.0-c"},
+			expectedRanked: []string{"1.0.0-a", "1.0.0-b", "1.0.0-c"},
+		},
+		{
+			label: "With no version left",
+			policy: &Composite{
+				Filters: []*RegexFilter{mustFilter(`.*`, "", true)},
+				Primary: mustSemVer(">=1.0"),
+			},
+			versions:  []string{"1.0.0"},
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.label, func(t *testing.T) {
+			ranked, err := tt.policy.Rank(tt.versions)
+			if tt.expectErr && err == nil {
+				t.Fatalf("expecting error, got nil")
+			}
+			if !tt.expectErr && err != nil {
+				t.Fatalf("returned unexpected error: %s", err)
+			}
+
+			if !reflect.DeepEqual(ranked, tt.expectedRanked) {
+				t.Errorf("incorrect ranked versions returned, got %v, expected %v", ranked, tt.expectedRanked)
+			}
+		})
+	}
+}
+
+func TestComposite_Skipped(t *testing.T) {
+	versions := []string{"v1", "10", "11", "latest", "main", "12a"}
+	filter := mustFilter(`^v`, "", true)
+
+	if skipped := (&Composite{Filters: []*RegexFilter{filter}, Primary: &Alphabetical{Order: AlphabeticalOrderAsc}}).Skipped(versions); skipped != 0 {
+		t.Errorf("incorrect number of skipped versions returned, got %d, expected 0", skipped)
+	}
+	if skipped := (&Composite{Filters: []*RegexFilter{filter}, Primary: mustSemVer(">=2.0.0")}).Skipped([]string{"1.0.0", "2.0.0", "latest"}); skipped != 1 {
+		t.Errorf("incorrect number of skipped versions returned, got %d, expected 1", skipped)
+	}
+	calver, err := NewCalVer("YYYY.MM", 0)
+	if err != nil {
+		t.Fatalf("returned unexpected error: %s", err)
+	}
+	if skipped := (&Composite{Filters: []*RegexFilter{filter}, Primary: calver}).Skipped([]string{"2023.1", "2023.13", "latest"}); skipped != 2 {
+		t.Errorf("incorrect number of skipped versions returned, got %d, expected 2", skipped)
+	}
+	numerical := &Numerical{Order: NumericalOrderAsc, SkipInvalid: true}
+	if skipped := (&Composite{Filters: []*RegexFilter{filter}, Primary: numerical}).Skipped(versions); skipped != 3 {
+		t.Errorf("incorrect number of skipped versions returned, got %d, expected 3", skipped)
+	}
+}
+
+func mustSemVer(r string) *SemVer {
+	p, err := NewSemVer(r)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+func mustFilter(pattern, extract string, exclude bool) *RegexFilter {
+	f, err := filterFromSpec(pattern, extract, exclude)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
@@ -19,6 +19,7 @@ package policy
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	imagev1 "github.com/fluxcd/image-reflector-controller/api/v1beta2"
 )
@@ -29,11 +30,29 @@ func PolicerFromSpec(choice imagev1.ImagePolicyChoice) (Policer, error) {
 	var err error
 	switch {
 	case choice.SemVer != nil:
-		p, err = NewSemVer(choice.SemVer.Range)
+		p, err = semVerFromSpec(choice.SemVer)
 	case choice.Alphabetical != nil:
-		p, err = NewAlphabetical(strings.ToUpper(choice.Alphabetical.Order))
+		var alphabetical *Alphabetical
+		alphabetical, err = NewAlphabetical(strings.ToUpper(choice.Alphabetical.Order))
+		if err == nil {
+			alphabetical.Natural = choice.Alphabetical.Natural
+		}
+		p = alphabetical
 	case choice.Numerical != nil:
-		p, err = NewNumerical(strings.ToUpper(choice.Numerical.Order))
+		var numerical *Numerical
+		numerical, err = NewNumerical(strings.ToUpper(choice.Numerical.Order))
+		if err == nil {
+			numerical.SkipInvalid = choice.Numerical.SkipInvalid
+		}
+		p = numerical
+	case choice.Newest != nil:
+		p, err = NewNewest(strings.ToUpper(choice.Newest.Source))
+	case choice.CalVer != nil:
+		var maxAge time.Duration
+		if choice.CalVer.MaxAge != nil {
+			maxAge = choice.CalVer.MaxAge.Duration
+		}
+		p, err = NewCalVer(choice.CalVer.Format, maxAge)
 	default:
 		return nil, fmt.Errorf("given ImagePolicyChoice object is invalid")
 	}
@@ -43,3 +62,67 @@ func PolicerFromSpec(choice imagev1.ImagePolicyChoice) (Policer, error) {
 	}
 	return p, nil
 }
+
+// CompositeFromSpec constructs a new composite policy object from the filters,
+// policy and tie-breaker of the given ImagePolicySpec
+func CompositeFromSpec(spec imagev1.ImagePolicySpec) (*Composite, error) {
+	var err error
+	p := &Composite{}
+	if p.Primary, err = PolicerFromSpec(spec.Policy); err != nil {
+		return nil, err
+	}
+
+	filters := spec.Filters
+	if spec.FilterTags != nil {
+		filters = append([]imagev1.TagFilter{*spec.FilterTags}, filters...)
+	}
+	for _, filter := range filters {
+		f, err := filterFromSpec(filter.Pattern, filter.Extract, filter.Exclude)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tag filter: %w", err)
+		}
+		p.Filters = append(p.Filters, f)
+	}
+
+	if tb := spec.TieBreaker; tb != nil {
+		if p.TieBreaker, err = PolicerFromSpec(tb.Policy); err != nil {
+			return nil, fmt.Errorf("invalid tie-breaker: %w", err)
+		}
+		if tb.Pattern != "" || tb.Extract != "" {
+			if p.TieBreakerFilter, err = filterFromSpec(tb.Pattern, tb.Extract, false); err != nil {
+				return nil, fmt.Errorf("invalid tie-breaker: %w", err)
+			}
+		}
+	}
+	return p, nil
+}
+
+func filterFromSpec(pattern, extract string, exclude bool) (*RegexFilter, error) {
+	f, err := NewRegexFilter(pattern, extract)
+	if err != nil {
+		return nil, err
+	}
+	if exclude {
+		if extract != "" {
+			return nil, fmt.Errorf("cannot extract values with an exclusion pattern '%s'", pattern)
+		}
+		f.Exclude = true
+	}
+	return f, nil
+}
+
+func semVerFromSpec(spec *imagev1.SemVerPolicy) (*SemVer, error) {
+	p, err := NewSemVer(spec.Range)
+	if err != nil {
+		return nil, err
+	}
+	p.Prereleases = spec.Prereleases
+	p.PreferPrerelease = spec.PreferPrerelease
+	switch order := strings.ToUpper(spec.BuildMetadataOrder); order {
+	case "", SemVerBuildMetadataAsc, SemVerBuildMetadataDesc:
+		p.BuildMetadataOrder = order
+	default:
+		return nil, fmt.Errorf("invalid build metadata order argument provided: '%s', must be one of: %s, %s", spec.BuildMetadataOrder, SemVerBuildMetadataAsc, SemVerBuildMetadataDesc)
+	}
+	return p, nil
+}
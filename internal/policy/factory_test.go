@@ -18,6 +18,9 @@ package policy
 
 import (
 	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	imagev1 "github.com/fluxcd/image-reflector-controller/api/v1beta2"
 )
@@ -41,6 +44,12 @@ func TestFactory_PolicerFromSpec(t *testing.T) {
 		t.Error("should not return error")
 	}
 
+	// With NewestPolicy
+	_, err = PolicerFromSpec(imagev1.ImagePolicyChoice{Newest: &imagev1.NewestPolicy{Source: "created"}})
+	if err != nil {
+		t.Error("should not return error")
+	}
+
 	// A nil checkable Policer for invalid policy.
 	p, err := PolicerFromSpec(imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Range: "*-*"}})
 	if err == nil {
@@ -50,3 +59,110 @@ func TestFactory_PolicerFromSpec(t *testing.T) {
 		t.Error("should be nil")
 	}
 }
+
+func TestFactory_CompositeFromSpec(t *testing.T) {
+	p, err := CompositeFromSpec(imagev1.ImagePolicySpec{
+		Policy:     imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Range: ">=1.0"}},
+		FilterTags: &imagev1.TagFilter{Pattern: "^v"},
+		Filters: []imagev1.TagFilter{
+			{Pattern: "-rc", Exclude: true},
+			{Pattern: "^v(?P<version>.*)$", Extract: "$version"},
+		},
+		TieBreaker: &imagev1.TieBreaker{
+			Policy: imagev1.ImagePolicyChoice{Numerical: &imagev1.NumericalPolicy{}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("returned unexpected error: %s", err)
+	}
+	if len(p.Filters) != 3 || !p.Filters[1].Exclude {
+		t.Errorf("incorrect filters, got %#v", p.Filters)
+	}
+	if p.TieBreaker == nil || p.TieBreakerFilter != nil {
+		t.Errorf("incorrect tie-breaker, got %#v", p)
+	}
+
+	for _, spec := range []imagev1.ImagePolicySpec{
+		{},
+		{
+			Policy:  imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Range: ">=1.0"}},
+			Filters: []imagev1.TagFilter{{Pattern: "[="}},
+		},
+		{
+			Policy:  imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Range: ">=1.0"}},
+			Filters: []imagev1.TagFilter{{Pattern: "-(?P<n>rc)", Extract: "$n", Exclude: true}},
+		},
+		{
+			Policy:     imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Range: ">=1.0"}},
+			TieBreaker: &imagev1.TieBreaker{},
+		},
+		{
+			Policy: imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Range: ">=1.0"}},
+			TieBreaker: &imagev1.TieBreaker{
+				Pattern: "[=",
+				Policy:  imagev1.ImagePolicyChoice{Numerical: &imagev1.NumericalPolicy{}},
+			},
+		},
+	} {
+		if _, err := CompositeFromSpec(spec); err == nil {
+			t.Errorf("expected error for %#v, got nil", spec)
+		}
+	}
+}
+
+func TestFactory_PolicerFromSpecCalVer(t *testing.T) {
+	p, err := PolicerFromSpec(imagev1.ImagePolicyChoice{CalVer: &imagev1.CalVerPolicy{
+		Format: "YYYY.0M.MICRO",
+		MaxAge: &metav1.Duration{Duration: time.Hour},
+	}})
+	if err != nil {
+		t.Fatalf("returned unexpected error: %s", err)
+	}
+	if calver, ok := p.(*CalVer); !ok || calver.MaxAge != time.Hour {
+		t.Errorf("incorrect policy returned, got %#v", p)
+	}
+
+	if _, err = PolicerFromSpec(imagev1.ImagePolicyChoice{CalVer: &imagev1.CalVerPolicy{Format: "MAJOR"}}); err == nil {
+		t.Error("should return error")
+	}
+}
+
+func TestFactory_PolicerFromSpecSemVerChannel(t *testing.T) {
+	p, err := PolicerFromSpec(imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{
+		Range:              ">=1.0",
+		Prereleases:        []string{"beta"},
+		PreferPrerelease:   true,
+		BuildMetadataOrder: "desc",
+	}})
+	if err != nil {
+		t.Fatalf("returned unexpected error: %s", err)
+	}
+	semver, ok := p.(*SemVer)
+	if !ok || len(semver.Prereleases) != 1 || !semver.PreferPrerelease || semver.BuildMetadataOrder != SemVerBuildMetadataDesc {
+		t.Errorf("incorrect policy returned, got %#v", p)
+	}
+
+	if _, err = PolicerFromSpec(imagev1.ImagePolicyChoice{SemVer: &imagev1.SemVerPolicy{Range: ">=1.0", BuildMetadataOrder: "up"}}); err == nil {
+		t.Error("should return error")
+	}
+}
+
+func TestFactory_PolicerFromSpecNatural(t *testing.T) {
+	p, err := PolicerFromSpec(imagev1.ImagePolicyChoice{Alphabetical: &imagev1.AlphabeticalPolicy{Natural: true}})
+	if err != nil {
+		t.Fatalf("returned unexpected error: %s", err)
+	}
+	if alphabetical, ok := p.(*Alphabetical); !ok || !alphabetical.Natural {
+		t.Errorf("incorrect policy returned, got %#v", p)
+	}
+}
+
+func TestFactory_PolicerFromSpecSkipInvalid(t *testing.T) {
+	p, err := PolicerFromSpec(imagev1.ImagePolicyChoice{Numerical: &imagev1.NumericalPolicy{SkipInvalid: true}})
+	if err != nil {
+		t.Fatalf("returned unexpected error: %s", err)
+	}
+	if numerical, ok := p.(*Numerical); !ok || !numerical.SkipInvalid {
+		t.Errorf("incorrect policy returned, got %#v", p)
+	}
+}
@@ -27,6 +27,9 @@ type RegexFilter struct {
 
 	Regexp  *regexp.Regexp
 	Replace string
+	// Exclude inverts the filter to drop the tags matching the expression,
+	// which are not replaced.
+	Exclude bool
 }
 
 // NewRegexFilter constructs new RegexFilter object
@@ -45,18 +48,28 @@ func NewRegexFilter(pattern string, replace string) (*RegexFilter, error) {
 func (f *RegexFilter) Apply(list []string) {
 	f.filtered = map[string]string{}
 	for _, item := range list {
-		if submatches := f.Regexp.FindStringSubmatchIndex(item); len(submatches) > 0 {
-			tag := item
-			if f.Replace != "" {
-				result := []byte{}
-				result = f.Regexp.ExpandString(result, f.Replace, item, submatches)
-				tag = string(result)
-			}
+		if tag, ok := f.Extract(item); ok {
 			f.filtered[tag] = item
 		}
 	}
 }
 
+// Extract returns the value of the given tag after replace extraction, and
+// whether the tag passes the filter
+func (f *RegexFilter) Extract(item string) (string, bool) {
+	submatches := f.Regexp.FindStringSubmatchIndex(item)
+	if f.Exclude {
+		return item, submatches == nil
+	}
+	if submatches == nil {
+		return "", false
+	}
+	if f.Replace == "" {
+		return item, true
+	}
+	return string(f.Regexp.ExpandString(nil, f.Replace, item, submatches)), true
+}
+
 // Items returns the list of filtered tags
 func (f *RegexFilter) Items() []string {
 	var filtered []string
@@ -61,6 +61,17 @@ func TestRegexFilter(t *testing.T) {
 	}
 }
 
+func TestRegexFilter_Exclude(t *testing.T) {
+	filter := newRegexFilter(`-rc\.`, "")
+	filter.Exclude = true
+	filter.Apply([]string{"1.0.0", "1.1.0-rc.1", "1.1.0"})
+	r := filter.Items()
+	sort.Strings(r)
+	if expected := []string{"1.0.0", "1.1.0"}; !reflect.DeepEqual(r, expected) {
+		t.Errorf("incorrect value returned, got '%s', expected '%s'", r, expected)
+	}
+}
+
 func newRegexFilter(pattern string, extract string) *RegexFilter {
 	f, _ := NewRegexFilter(pattern, extract)
 	return f
@@ -0,0 +1,93 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+const (
+	// NewestSourceFirstSeen orders by the time the tags were first seen
+	NewestSourceFirstSeen = "FIRSTSEEN"
+	// NewestSourceCreated orders by the creation time of the images
+	NewestSourceCreated = "CREATED"
+)
+
+// Newest represents a policy selecting the tag with the most recent time
+type Newest struct {
+	Source string
+
+	// Times holds the time of each tag to order by. Tags without a time are
+	// not considered.
+	Times map[string]time.Time
+}
+
+// NewNewest constructs a Newest object validating the provided source
+// argument
+func NewNewest(source string) (*Newest, error) {
+	switch source {
+	case "":
+		source = NewestSourceFirstSeen
+	case NewestSourceFirstSeen, NewestSourceCreated:
+		break
+	default:
+		return nil, fmt.Errorf("invalid source argument provided: '%s', must be one of: %s, %s", source, NewestSourceFirstSeen, NewestSourceCreated)
+	}
+
+	return &Newest{
+		Source: source,
+	}, nil
+}
+
+// Latest returns the version with the most recent time from a provided list of
+// strings. Versions with equal times are ordered alphabetically.
+func (p *Newest) Latest(versions []string) (string, error) {
+	ranked, err := p.Rank(versions)
+	if err != nil {
+		return "", err
+	}
+	return ranked[0], nil
+}
+
+// Rank returns the versions with a time of a provided list of strings, from the
+// most recent to the oldest.
+func (p *Newest) Rank(versions []string) ([]string, error) {
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("version list argument cannot be empty")
+	}
+
+	var ranked []string
+	for _, version := range versions {
+		if _, ok := p.Times[version]; ok {
+			ranked = append(ranked, version)
+		}
+	}
+	if len(ranked) == 0 {
+		return nil, fmt.Errorf("no time recorded for any of the versions")
+	}
+
+	sort.Slice(ranked, func(i, j int) bool {
+		ti, tj := p.Times[ranked[i]], p.Times[ranked[j]]
+		if ti.Equal(tj) {
+			return ranked[i] > ranked[j]
+		}
+		return ti.After(tj)
+	})
+	return ranked, nil
+}
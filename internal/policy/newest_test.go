@@ -0,0 +1,167 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNewNewest(t *testing.T) {
+	cases := []struct {
+		label     string
+		source    string
+		expectErr bool
+	}{
+		{
+			label:  "With valid empty source",
+			source: "",
+		},
+		{
+			label:  "With valid first seen source",
+			source: NewestSourceFirstSeen,
+		},
+		{
+			label:  "With valid created source",
+			source: NewestSourceCreated,
+		},
+		{
+			label:     "With invalid source",
+			source:    "invalid",
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.label, func(t *testing.T) {
+			_, err := NewNewest(tt.source)
+			if tt.expectErr && err == nil {
+				t.Fatalf("expecting error, got nil")
+			}
+			if !tt.expectErr && err != nil {
+				t.Fatalf("returned unexpected error: %s", err)
+			}
+		})
+	}
+}
+
+func TestNewest_Latest(t *testing.T) {
+	base := time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		label           string
+		times           map[string]time.Time
+		versions        []string
+		expectedVersion string
+		expectErr       bool
+	}{
+		{
+			label: "With unordered list of versions",
+			times: map[string]time.Time{
+				"bbb": base.Add(time.Hour),
+				"aaa": base.Add(3 * time.Hour),
+				"ccc": base.Add(2 * time.Hour),
+			},
+			versions:        shuffle([]string{"aaa", "bbb", "ccc"}),
+			expectedVersion: "aaa",
+		},
+		{
+			label: "With equal times",
+			times: map[string]time.Time{
+				"aaa": base,
+				"ccc": base,
+				"bbb": base,
+			},
+			versions:        shuffle([]string{"aaa", "bbb", "ccc"}),
+			expectedVersion: "ccc",
+		},
+		{
+			label: "With versions without time",
+			times: map[string]time.Time{
+				"aaa": base,
+			},
+			versions:        shuffle([]string{"aaa", "bbb", "ccc"}),
+			expectedVersion: "aaa",
+		},
+		{
+			label: "With times of versions not in the list",
+			times: map[string]time.Time{
+				"aaa": base,
+				"zzz": base.Add(time.Hour),
+			},
+			versions:        []string{"aaa", "bbb"},
+			expectedVersion: "aaa",
+		},
+		{
+			label:     "With no times",
+			versions:  []string{"aaa", "bbb"},
+			expectErr: true,
+		},
+		{
+			label:     "With empty list",
+			times:     map[string]time.Time{"aaa": base},
+			versions:  []string{},
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.label, func(t *testing.T) {
+			policy, err := NewNewest("")
+			if err != nil {
+				t.Fatalf("returned unexpected error: %s", err)
+			}
+			policy.Times = tt.times
+
+			latest, err := policy.Latest(tt.versions)
+			if tt.expectErr && err == nil {
+				t.Fatalf("expecting error, got nil")
+			}
+			if !tt.expectErr && err != nil {
+				t.Fatalf("returned unexpected error: %s", err)
+			}
+
+			if latest != tt.expectedVersion {
+				t.Errorf("incorrect computed version returned, got '%s', expected '%s'", latest, tt.expectedVersion)
+			}
+		})
+	}
+}
+
+func TestNewest_Rank(t *testing.T) {
+	base := time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	policy, err := NewNewest(NewestSourceFirstSeen)
+	if err != nil {
+		t.Fatalf("returned unexpected error: %s", err)
+	}
+	policy.Times = map[string]time.Time{
+		"aaa": base,
+		"bbb": base.Add(time.Hour),
+		"ccc": base,
+		"ddd": base.Add(-time.Hour),
+	}
+	ranked, err := policy.Rank(shuffle([]string{"aaa", "bbb", "ccc", "ddd", "eee"}))
+	if err != nil {
+		t.Fatalf("returned unexpected error: %s", err)
+	}
+	expected := []string{"bbb", "ccc", "aaa", "ddd"}
+	if !reflect.DeepEqual(ranked, expected) {
+		t.Errorf("incorrect ranked versions returned, got %v, expected %v", ranked, expected)
+	}
+}
@@ -18,7 +18,11 @@ package policy
 
 import (
 	"fmt"
+	"math"
+	"math/big"
+	"sort"
 	"strconv"
+	"strings"
 )
 
 const (
@@ -31,6 +35,9 @@ const (
 // Numerical representes a Numerical ordering policy
 type Numerical struct {
 	Order string
+	// SkipInvalid skips the versions which are not numbers instead of
+	// failing.
+	SkipInvalid bool
 }
 
 // NewNumerical constructs a Numerical object validating the provided
@@ -52,28 +59,104 @@ func NewNumerical(order string) (*Numerical, error) {
 
 // Latest returns latest version from a provided list of strings
 func (p *Numerical) Latest(versions []string) (string, error) {
+	ranked, err := p.Rank(versions)
+	if err != nil {
+		return "", err
+	}
+	return ranked[0], nil
+}
+
+// Rank returns a provided list of strings ordered from the latest to the
+// oldest version. Of equal values, the last one in the list ranks first.
+func (p *Numerical) Rank(versions []string) ([]string, error) {
 	if len(versions) == 0 {
-		return "", fmt.Errorf("version list argument cannot be empty")
+		return nil, fmt.Errorf("version list argument cannot be empty")
 	}
 
-	var latest string
-	var pv float64
-	for i, version := range versions {
-		cv, err := strconv.ParseFloat(version, 64)
-		if err != nil {
-			return "", fmt.Errorf("failed to parse invalid numeric value '%s'", version)
+	type numeric struct {
+		version string
+		value   numericValue
+	}
+	candidates := make([]numeric, 0, len(versions))
+	// Iterate in reverse so that the stable sort ranks the last of equal
+	// values first.
+	for i := len(versions) - 1; i >= 0; i-- {
+		version := versions[i]
+		cv, ok := parseNumeric(version)
+		if !ok {
+			if p.SkipInvalid {
+				continue
+			}
+			return nil, fmt.Errorf("failed to parse invalid numeric value '%s'", version)
 		}
+		candidates = append(candidates, numeric{version: version, value: cv})
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("unable to determine latest version from provided list")
+	}
 
-		switch {
-		case i == 0:
-			// First iteration, nothing to compare
-		case p.Order == NumericalOrderAsc && cv < pv, p.Order == NumericalOrderDesc && cv > pv:
-			continue
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if p.Order == NumericalOrderDesc {
+			return candidates[i].value.cmp(candidates[j].value) < 0
 		}
+		return candidates[i].value.cmp(candidates[j].value) > 0
+	})
+	ranked := make([]string, len(candidates))
+	for i, c := range candidates {
+		ranked[i] = c.version
+	}
+	return ranked, nil
+}
 
-		latest = version
-		pv = cv
+// Valid returns whether the given version is a number.
+func (p *Numerical) Valid(version string) bool {
+	_, ok := parseNumeric(version)
+	return ok
+}
+
+// numericValue holds a number exactly, or an infinity when inf is not zero.
+type numericValue struct {
+	rat *big.Rat
+	inf int
+}
+
+// cmp compares the value to the given one, returning -1, 0 or +1.
+func (v numericValue) cmp(o numericValue) int {
+	switch {
+	case v.inf != 0 || o.inf != 0:
+		if v.inf < o.inf {
+			return -1
+		}
+		if v.inf > o.inf {
+			return 1
+		}
+		return 0
+	default:
+		return v.rat.Cmp(o.rat)
 	}
+}
 
-	return latest, nil
+// parseNumeric parses the numbers accepted by strconv.ParseFloat. Decimal
+// numbers without exponent are parsed exactly so that large integers, e.g.
+// timestamps with nanoseconds, compare correctly beyond the precision of a
+// float64.
+func parseNumeric(s string) (numericValue, bool) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(f) {
+		return numericValue{}, false
+	}
+	if math.IsInf(f, 0) {
+		if f > 0 {
+			return numericValue{inf: 1}, true
+		}
+		return numericValue{inf: -1}, true
+	}
+	// Exponents are parsed as floats for a big exponent not to take up
+	// memory.
+	if !strings.ContainsAny(s, "eEpPxX") {
+		if r, ok := new(big.Rat).SetString(s); ok {
+			return numericValue{rat: r}, true
+		}
+	}
+	return numericValue{rat: new(big.Rat).SetFloat64(f)}, true
 }
@@ -18,6 +18,7 @@ package policy
 
 import (
 	"math/rand"
+	"reflect"
 	"testing"
 	"time"
 )
@@ -101,6 +102,17 @@ func TestNumerical_Latest(t *testing.T) {
 			order:           NumericalOrderDesc,
 			expectedVersion: "1606234201",
 		},
+		{
+			label:           "With integers beyond float64 precision ascending",
+			versions:        shuffle([]string{"20230101120000000001", "20230101120000000003", "20230101120000000002"}),
+			expectedVersion: "20230101120000000003",
+		},
+		{
+			label:           "With integers beyond float64 precision descending",
+			versions:        shuffle([]string{"20230101120000000001", "20230101120000000003", "20230101120000000002"}),
+			order:           NumericalOrderDesc,
+			expectedVersion: "20230101120000000001",
+		},
 		{
 			label:           "With single value ascending",
 			versions:        []string{"1"},
@@ -150,3 +162,68 @@ func shuffle(list []string) []string {
 	rand.Shuffle(len(list), func(i, j int) { list[i], list[j] = list[j], list[i] })
 	return list
 }
+
+func TestNumerical_Rank(t *testing.T) {
+	versions := []string{"5", "-8", "25.5", "0", "1e2"}
+	for order, expected := range map[string][]string{
+		NumericalOrderAsc:  {"1e2", "25.5", "5", "0", "-8"},
+		NumericalOrderDesc: {"-8", "0", "5", "25.5", "1e2"},
+	} {
+		policy, err := NewNumerical(order)
+		if err != nil {
+			t.Fatalf("returned unexpected error: %s", err)
+		}
+		ranked, err := policy.Rank(shuffle(append([]string(nil), versions...)))
+		if err != nil {
+			t.Fatalf("returned unexpected error: %s", err)
+		}
+		if !reflect.DeepEqual(ranked, expected) {
+			t.Errorf("incorrect ranked versions returned for order %s, got %v, expected %v", order, ranked, expected)
+		}
+	}
+
+	policy, _ := NewNumerical(NumericalOrderAsc)
+	if _, err := policy.Rank([]string{"1", "a"}); err == nil {
+		t.Fatalf("expecting error, got nil")
+	}
+}
+
+func TestNumerical_RankSkipInvalid(t *testing.T) {
+	cases := []struct {
+		label     string
+		versions  []string
+		expected  []string
+		expectErr bool
+	}{
+		{
+			label:    "With invalid values",
+			versions: []string{"3", "latest", "1e2", "nan", "1a", "-Inf", "20230101120000000001"},
+			expected: []string{"20230101120000000001", "1e2", "3", "-Inf"},
+		},
+		{
+			label:     "With only invalid values",
+			versions:  []string{"latest", "main"},
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.label, func(t *testing.T) {
+			policy, err := NewNumerical(NumericalOrderAsc)
+			if err != nil {
+				t.Fatalf("returned unexpected error: %s", err)
+			}
+			policy.SkipInvalid = true
+			ranked, err := policy.Rank(shuffle(tt.versions))
+			if tt.expectErr && err == nil {
+				t.Fatalf("expecting error, got nil")
+			}
+			if !tt.expectErr && err != nil {
+				t.Fatalf("returned unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(ranked, tt.expected) {
+				t.Errorf("incorrect ranked versions returned, got %v, expected %v", ranked, tt.expected)
+			}
+		})
+	}
+}
@@ -18,5 +18,16 @@ package policy
 
 // Policer is an interface representing a policy implementation type
 type Policer interface {
+	// Latest returns the latest of the given versions according to the policy.
 	Latest([]string) (string, error)
+	// Rank returns the given versions considered by the policy, ordered from
+	// the latest to the oldest. Latest returns the first of them.
+	Rank([]string) ([]string, error)
+}
+
+// Validator is implemented by the policies which can skip the versions they
+// cannot parse.
+type Validator interface {
+	// Valid returns whether the given version can be parsed by the policy.
+	Valid(string) bool
 }
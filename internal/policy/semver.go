@@ -18,14 +18,42 @@ package policy
 
 import (
 	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/Masterminds/semver/v3"
 	"github.com/fluxcd/pkg/version"
 )
 
+const (
+	// SemVerBuildMetadataAsc ranks equal versions by ascending order of their
+	// build metadata, i.e. the highest build metadata ranks first
+	SemVerBuildMetadataAsc = "ASC"
+	// SemVerBuildMetadataDesc ranks equal versions by descending order of
+	// their build metadata, i.e. the lowest build metadata ranks first
+	SemVerBuildMetadataDesc = "DESC"
+)
+
+// prereleaseIdentifier matches the identifier of the channel of a prerelease,
+// e.g. 'rc' in 'rc.1' or 'beta' in 'beta2'.
+var prereleaseIdentifier = regexp.MustCompile(`^[a-zA-Z]+`)
+
 // SemVer representes a SemVer policy
 type SemVer struct {
 	Range string
+	// Prereleases lists the identifiers of the prereleases to consider, e.g.
+	// 'rc' or 'beta'. When not empty, the prereleases with these identifiers
+	// are considered if their final release is within the range, and the
+	// other prereleases are not.
+	Prereleases []string
+	// PreferPrerelease ranks the prereleases of a version above its final
+	// release.
+	PreferPrerelease bool
+	// BuildMetadataOrder orders the versions differing only by their build
+	// metadata. They are ranked in the order of the provided list when empty.
+	BuildMetadataOrder string
 
 	constraint *semver.Constraints
 }
@@ -45,21 +73,128 @@ func NewSemVer(r string) (*SemVer, error) {
 
 // Latest returns latest version from a provided list of strings
 func (p *SemVer) Latest(versions []string) (string, error) {
+	ranked, err := p.Rank(versions)
+	if err != nil {
+		return "", err
+	}
+	return ranked[0], nil
+}
+
+// Rank returns the versions of a provided list of strings within the range,
+// from the highest to the lowest
+func (p *SemVer) Rank(versions []string) ([]string, error) {
 	if len(versions) == 0 {
-		return "", fmt.Errorf("version list argument cannot be empty")
+		return nil, fmt.Errorf("version list argument cannot be empty")
 	}
 
-	var latestVersion *semver.Version
+	var candidates []*semver.Version
 	for _, tag := range versions {
 		if v, err := version.ParseVersion(tag); err == nil {
-			if p.constraint.Check(v) && (latestVersion == nil || v.GreaterThan(latestVersion)) {
-				latestVersion = v
+			if p.check(v) {
+				candidates = append(candidates, v)
 			}
 		}
 	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("unable to determine latest version from provided list")
+	}
 
-	if latestVersion != nil {
-		return latestVersion.Original(), nil
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return p.compare(candidates[i], candidates[j]) > 0
+	})
+	ranked := make([]string, len(candidates))
+	for i, v := range candidates {
+		ranked[i] = v.Original()
+	}
+	return ranked, nil
+}
+
+// Valid returns whether the given version is a semantic version.
+func (p *SemVer) Valid(tag string) bool {
+	_, err := version.ParseVersion(tag)
+	return err == nil
+}
+
+// check returns whether the given version is considered by the policy.
+func (p *SemVer) check(v *semver.Version) bool {
+	if len(p.Prereleases) == 0 || v.Prerelease() == "" {
+		return p.constraint.Check(v)
+	}
+
+	id := prereleaseIdentifier.FindString(v.Prerelease())
+	for _, allowed := range p.Prereleases {
+		if strings.EqualFold(id, allowed) {
+			release, err := v.SetPrerelease("")
+			return err == nil && p.constraint.Check(&release)
+		}
+	}
+	return false
+}
+
+// compare returns 1 if the version a ranks above b, -1 if b ranks above a, and
+// 0 if they rank equally.
+func (p *SemVer) compare(a, b *semver.Version) int {
+	if p.PreferPrerelease && (a.Prerelease() == "") != (b.Prerelease() == "") {
+		if a.Major() == b.Major() && a.Minor() == b.Minor() && a.Patch() == b.Patch() {
+			if a.Prerelease() != "" {
+				return 1
+			}
+			return -1
+		}
+	}
+	if c := a.Compare(b); c != 0 {
+		return c
+	}
+
+	switch p.BuildMetadataOrder {
+	case SemVerBuildMetadataAsc:
+		return compareBuildMetadata(a.Metadata(), b.Metadata())
+	case SemVerBuildMetadataDesc:
+		return compareBuildMetadata(b.Metadata(), a.Metadata())
+	}
+	return 0
+}
+
+// compareBuildMetadata compares build metadata like prereleases: identifier by
+// identifier, numerically if both are numeric. Missing build metadata is
+// lower than any.
+func compareBuildMetadata(a, b string) int {
+	switch {
+	case a == b:
+		return 0
+	case a == "":
+		return -1
+	case b == "":
+		return 1
+	}
+
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		an, aErr := strconv.ParseUint(as[i], 10, 64)
+		bn, bErr := strconv.ParseUint(bs[i], 10, 64)
+		switch {
+		case aErr == nil && bErr == nil:
+			if an != bn {
+				if an > bn {
+					return 1
+				}
+				return -1
+			}
+		case aErr == nil:
+			return -1
+		case bErr == nil:
+			return 1
+		default:
+			if c := strings.Compare(as[i], bs[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	switch {
+	case len(as) > len(bs):
+		return 1
+	case len(as) < len(bs):
+		return -1
 	}
-	return "", fmt.Errorf("unable to determine latest version from provided list")
+	return 0
 }
@@ -17,6 +17,7 @@ limitations under the License.
 package policy
 
 import (
+	"reflect"
 	"testing"
 )
 
@@ -113,3 +114,145 @@ func TestSemVer_Latest(t *testing.T) {
 		})
 	}
 }
+
+func TestSemVer_Rank(t *testing.T) {
+	cases := []struct {
+		label          string
+		semverRange    string
+		versions       []string
+		expectedRanked []string
+		expectErr      bool
+	}{
+		{
+			label:          "With versions out of range",
+			versions:       []string{"1.0.0", "1.0.0p", "1.2.0", "v1.1.0", "2.0.0", "0.1.0"},
+			semverRange:    "1.x",
+			expectedRanked: []string{"1.2.0", "v1.1.0", "1.0.0"},
+		},
+		{
+			label:       "With no version in range",
+			versions:    []string{"2.0.0", "0.1.0"},
+			semverRange: "1.x",
+			expectErr:   true,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.label, func(t *testing.T) {
+			policy, err := NewSemVer(tt.semverRange)
+			if err != nil {
+				t.Fatalf("returned unexpected error: %s", err)
+			}
+			ranked, err := policy.Rank(tt.versions)
+			if tt.expectErr && err == nil {
+				t.Fatalf("expecting error, got nil")
+			}
+			if !tt.expectErr && err != nil {
+				t.Fatalf("returned unexpected error: %s", err)
+			}
+
+			if !reflect.DeepEqual(ranked, tt.expectedRanked) {
+				t.Errorf("incorrect ranked versions returned, got %v, expected %v", ranked, tt.expectedRanked)
+			}
+		})
+	}
+}
+
+func TestSemVer_RankChannels(t *testing.T) {
+	cases := []struct {
+		label              string
+		semverRange        string
+		prereleases        []string
+		preferPrerelease   bool
+		buildMetadataOrder string
+		versions           []string
+		expectedRanked     []string
+		expectErr          bool
+	}{
+		{
+			label:          "Without prerelease identifiers",
+			semverRange:    ">=1.0.0",
+			versions:       []string{"1.0.0", "1.1.0-rc.1", "1.1.0-beta.1"},
+			expectedRanked: []string{"1.0.0"},
+		},
+		{
+			label:          "With prerelease identifiers",
+			semverRange:    ">=1.0.0",
+			prereleases:    []string{"beta"},
+			versions:       []string{"1.0.0", "1.1.0-rc.1", "1.1.0-beta.1", "1.1.0-beta2", "0.9.0-beta.1"},
+			expectedRanked: []string{"1.1.0-beta2", "1.1.0-beta.1", "1.0.0"},
+		},
+		{
+			label:          "With prerelease identifiers and prerelease range",
+			semverRange:    ">=1.0.0-0",
+			prereleases:    []string{"RC"},
+			versions:       []string{"1.0.0", "1.1.0-rc.1", "1.1.0-beta.1"},
+			expectedRanked: []string{"1.1.0-rc.1", "1.0.0"},
+		},
+		{
+			label:          "With final release",
+			semverRange:    ">=1.0.0",
+			prereleases:    []string{"beta"},
+			versions:       []string{"1.0.0", "1.1.0-beta.1", "1.1.0-beta.2", "1.1.0"},
+			expectedRanked: []string{"1.1.0", "1.1.0-beta.2", "1.1.0-beta.1", "1.0.0"},
+		},
+		{
+			label:            "With prereleases preferred",
+			semverRange:      ">=1.0.0",
+			prereleases:      []string{"beta"},
+			preferPrerelease: true,
+			versions:         []string{"1.0.0", "1.1.0-beta.1", "1.1.0-beta.2", "1.1.0", "1.2.0"},
+			expectedRanked:   []string{"1.2.0", "1.1.0-beta.2", "1.1.0-beta.1", "1.1.0", "1.0.0"},
+		},
+		{
+			label:          "With build metadata ignored",
+			semverRange:    ">=1.0.0",
+			versions:       []string{"1.0.0+2", "1.0.0+10", "1.0.0+1"},
+			expectedRanked: []string{"1.0.0+2", "1.0.0+10", "1.0.0+1"},
+		},
+		{
+			label:              "With build metadata ascending",
+			semverRange:        ">=1.0.0",
+			buildMetadataOrder: SemVerBuildMetadataAsc,
+			versions:           []string{"1.0.0+2", "1.0.0+10", "1.0.0", "1.0.0+1", "1.0.0+1.a", "1.0.0+b"},
+			expectedRanked:     []string{"1.0.0+b", "1.0.0+10", "1.0.0+2", "1.0.0+1.a", "1.0.0+1", "1.0.0"},
+		},
+		{
+			label:              "With build metadata descending",
+			semverRange:        ">=1.0.0",
+			buildMetadataOrder: SemVerBuildMetadataDesc,
+			versions:           []string{"1.0.0+2", "1.0.0+10", "0.9.0+99", "1.0.0+1"},
+			expectedRanked:     []string{"1.0.0+1", "1.0.0+2", "1.0.0+10"},
+		},
+		{
+			label:       "With no prerelease of the channel",
+			semverRange: ">=2.0.0",
+			prereleases: []string{"beta"},
+			versions:    []string{"1.0.0", "2.0.0-rc.1"},
+			expectErr:   true,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.label, func(t *testing.T) {
+			policy, err := NewSemVer(tt.semverRange)
+			if err != nil {
+				t.Fatalf("returned unexpected error: %s", err)
+			}
+			policy.Prereleases = tt.prereleases
+			policy.PreferPrerelease = tt.preferPrerelease
+			policy.BuildMetadataOrder = tt.buildMetadataOrder
+
+			ranked, err := policy.Rank(tt.versions)
+			if tt.expectErr && err == nil {
+				t.Fatalf("expecting error, got nil")
+			}
+			if !tt.expectErr && err != nil {
+				t.Fatalf("returned unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(ranked, tt.expectedRanked) {
+				t.Errorf("incorrect ranked versions returned, got %v, expected %v", ranked, tt.expectedRanked)
+			}
+		})
+	}
+}
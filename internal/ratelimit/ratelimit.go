@@ -0,0 +1,256 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"golang.org/x/time/rate"
+	"sigs.k8s.io/yaml"
+)
+
+// DefaultBackoff is the time requests to a registry are held back after it
+// responded with HTTP 429 without a Retry-After header.
+const DefaultBackoff = time.Minute
+
+// MinBackoff is the shortest time requests to a registry are held back after
+// it responded with HTTP 429, whatever its Retry-After header says, for the
+// throttled scans not to be retried straight away.
+const MinBackoff = time.Second
+
+// Limit is the rate of requests allowed to a registry.
+type Limit struct {
+	// QPS is the number of requests per second. Zero means unlimited.
+	QPS float64 `json:"qps"`
+	// Burst is the number of requests that can be made at once.
+	Burst int `json:"burst"`
+}
+
+// Config configures the rate of requests allowed to the registries.
+type Config struct {
+	// Default is the limit of the registries without a limit of their own.
+	Default Limit `json:"default"`
+	// Registries are the limits of specific registries, keyed by host.
+	Registries map[string]Limit `json:"registries,omitempty"`
+}
+
+// LoadConfig reads a YAML config from the file at the given path, e.g. a
+// mounted ConfigMap, falling back to the given default limit for the registries
+// not listed.
+func LoadConfig(path string, def Limit) (Config, error) {
+	config := Config{Default: def}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return config, err
+	}
+	if err := yaml.UnmarshalStrict(b, &config); err != nil {
+		return config, fmt.Errorf("failed to parse rate limit config %q: %w", path, err)
+	}
+	return config, nil
+}
+
+// ThrottledError is returned for requests held back by the rate limit of a
+// registry, or rejected by the registry with HTTP 429.
+type ThrottledError struct {
+	Registry   string
+	RetryAfter time.Duration
+}
+
+// Error implements error.
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("requests to %s are rate limited, retry after %s", e.Registry, e.RetryAfter.Round(time.Second))
+}
+
+// Limiter is a set of token buckets shared by all the requests to a registry,
+// one per registry host.
+type Limiter struct {
+	config Config
+
+	mu    sync.Mutex
+	hosts map[string]*host
+}
+
+type host struct {
+	limiter *rate.Limiter
+	// backoffUntil is the time until which requests are held back, after the
+	// registry responded with HTTP 429.
+	backoffUntil time.Time
+}
+
+// New returns a Limiter for the given config.
+func New(config Config) *Limiter {
+	registries := make(map[string]Limit, len(config.Registries))
+	for k, v := range config.Registries {
+		// Normalize the hosts, e.g. docker.io to index.docker.io.
+		if reg, err := name.NewRegistry(k); err == nil {
+			k = reg.RegistryStr()
+		}
+		registries[k] = v
+	}
+	config.Registries = registries
+	return &Limiter{
+		config: config,
+		hosts:  map[string]*host{},
+	}
+}
+
+func (l *Limiter) host(registry string) *host {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	h, ok := l.hosts[registry]
+	if !ok {
+		limit, ok := l.config.Registries[registry]
+		if !ok {
+			limit = l.config.Default
+		}
+		h = &host{}
+		if limit.QPS > 0 {
+			burst := limit.Burst
+			if burst < 1 {
+				burst = 1
+			}
+			h.limiter = rate.NewLimiter(rate.Limit(limit.QPS), burst)
+		}
+		l.hosts[registry] = h
+	}
+	return h
+}
+
+// Wait blocks until a request to the registry is allowed. If the request can't
+// be made before the deadline of the context, a ThrottledError is returned
+// straight away.
+func (l *Limiter) Wait(ctx context.Context, registry string) error {
+	h := l.host(registry)
+	now := time.Now()
+
+	l.mu.Lock()
+	backoffUntil := h.backoffUntil
+	l.mu.Unlock()
+	if now.Before(backoffUntil) {
+		if err := sleep(ctx, registry, backoffUntil.Sub(now)); err != nil {
+			return err
+		}
+		now = time.Now()
+	}
+
+	if h.limiter == nil {
+		return nil
+	}
+	r := h.limiter.ReserveN(now, 1)
+	if !r.OK() {
+		return &ThrottledError{Registry: registry, RetryAfter: DefaultBackoff}
+	}
+	delay := r.DelayFrom(now)
+	if err := sleep(ctx, registry, delay); err != nil {
+		r.CancelAt(now)
+		return err
+	}
+	return nil
+}
+
+// Backoff holds back the requests to the registry for the given duration.
+func (l *Limiter) Backoff(registry string, d time.Duration) {
+	h := l.host(registry)
+	until := time.Now().Add(d)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until.After(h.backoffUntil) {
+		h.backoffUntil = until
+	}
+}
+
+// Transport returns a transport waiting for the rate limit of the registry
+// before every request. Responses with HTTP 429 hold back the following
+// requests to the registry for the duration given in their Retry-After header,
+// and are returned as a ThrottledError.
+func (l *Limiter) Transport(base http.RoundTripper) http.RoundTripper {
+	return &transport{limiter: l, base: base}
+}
+
+type transport struct {
+	limiter *Limiter
+	base    http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	registry := req.URL.Host
+	if err := t.limiter.Wait(req.Context(), registry); err != nil {
+		return nil, err
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusTooManyRequests {
+		return resp, err
+	}
+
+	retryAfter := parseRetryAfter(resp.Header.Get("Retry-After"))
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	t.limiter.Backoff(registry, retryAfter)
+	return nil, &ThrottledError{Registry: registry, RetryAfter: retryAfter}
+}
+
+// parseRetryAfter parses the value of a Retry-After header, given either in
+// seconds or as an HTTP date. DefaultBackoff is returned if the value is
+// missing or invalid, and MinBackoff if it is shorter, e.g. zero or a past date.
+func parseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return DefaultBackoff
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return atLeastMinBackoff(time.Duration(secs) * time.Second)
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return atLeastMinBackoff(time.Until(t))
+	}
+	return DefaultBackoff
+}
+
+// atLeastMinBackoff returns d, or MinBackoff if d is shorter.
+func atLeastMinBackoff(d time.Duration) time.Duration {
+	if d < MinBackoff {
+		return MinBackoff
+	}
+	return d
+}
+
+// sleep waits for the given duration, unless it would exceed the deadline of
+// the context.
+func sleep(ctx context.Context, registry string, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < d {
+		return &ThrottledError{Registry: registry, RetryAfter: d}
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,151 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestLoadConfig(t *testing.T) {
+	g := NewWithT(t)
+
+	path := filepath.Join(t.TempDir(), "limits.yaml")
+	g.Expect(os.WriteFile(path, []byte(`
+registries:
+  docker.io:
+    qps: 0.5
+    burst: 5
+  ghcr.io:
+    qps: 10
+`), 0o600)).To(Succeed())
+
+	config, err := LoadConfig(path, Limit{QPS: 1, Burst: 2})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(config.Default).To(Equal(Limit{QPS: 1, Burst: 2}))
+	g.Expect(config.Registries).To(Equal(map[string]Limit{
+		"docker.io": {QPS: 0.5, Burst: 5},
+		"ghcr.io":   {QPS: 10},
+	}))
+
+	l := New(config)
+	g.Expect(l.config.Registries).To(HaveKey("index.docker.io"))
+
+	g.Expect(os.WriteFile(path, []byte(`registries: {ghcr.io: {rps: 1}}`), 0o600)).To(Succeed())
+	_, err = LoadConfig(path, Limit{})
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestLimiter_Wait(t *testing.T) {
+	g := NewWithT(t)
+
+	l := New(Config{
+		Registries: map[string]Limit{"limited.example.com": {QPS: 10, Burst: 1}},
+	})
+
+	// Unlimited registry.
+	for i := 0; i < 10; i++ {
+		g.Expect(l.Wait(context.TODO(), "example.com")).To(Succeed())
+	}
+
+	// The second request waits for a token.
+	start := time.Now()
+	g.Expect(l.Wait(context.TODO(), "limited.example.com")).To(Succeed())
+	g.Expect(l.Wait(context.TODO(), "limited.example.com")).To(Succeed())
+	g.Expect(time.Since(start)).To(BeNumerically(">=", 80*time.Millisecond))
+
+	// A request that can't be made before the deadline is throttled.
+	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Millisecond)
+	defer cancel()
+	err := l.Wait(ctx, "limited.example.com")
+	var throttled *ThrottledError
+	g.Expect(errors.As(err, &throttled)).To(BeTrue())
+	g.Expect(throttled.Registry).To(Equal("limited.example.com"))
+
+	// Backoff holds back the requests to the registry only.
+	l.Backoff("example.com", time.Minute)
+	ctx, cancel = context.WithTimeout(context.TODO(), time.Second)
+	defer cancel()
+	err = l.Wait(ctx, "example.com")
+	g.Expect(errors.As(err, &throttled)).To(BeTrue())
+	g.Expect(throttled.RetryAfter).To(BeNumerically("~", time.Minute, time.Second))
+	g.Expect(l.Wait(ctx, "other.example.com")).To(Succeed())
+}
+
+func TestLimiter_Transport(t *testing.T) {
+	g := NewWithT(t)
+
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if r.URL.Path == "/throttled" {
+			w.Header().Set("Retry-After", "120")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+	u, err := url.Parse(srv.URL)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	l := New(Config{})
+	client := &http.Client{Transport: l.Transport(http.DefaultTransport)}
+
+	resp, err := client.Get(srv.URL + "/ok")
+	g.Expect(err).ToNot(HaveOccurred())
+	resp.Body.Close()
+	g.Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+	_, err = client.Get(srv.URL + "/throttled")
+	var throttled *ThrottledError
+	g.Expect(errors.As(err, &throttled)).To(BeTrue())
+	g.Expect(throttled.Registry).To(Equal(u.Host))
+	g.Expect(throttled.RetryAfter).To(Equal(120 * time.Second))
+	g.Expect(atomic.LoadInt32(&requests)).To(Equal(int32(2)))
+
+	// The following requests are held back without reaching the registry.
+	ctx, cancel := context.WithTimeout(context.TODO(), time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/ok", nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = client.Do(req)
+	g.Expect(errors.As(err, &throttled)).To(BeTrue())
+	g.Expect(atomic.LoadInt32(&requests)).To(Equal(int32(2)))
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(parseRetryAfter("")).To(Equal(DefaultBackoff))
+	g.Expect(parseRetryAfter("30")).To(Equal(30 * time.Second))
+	g.Expect(parseRetryAfter("0")).To(Equal(MinBackoff))
+	g.Expect(parseRetryAfter("invalid")).To(Equal(DefaultBackoff))
+	g.Expect(parseRetryAfter(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))).
+		To(BeNumerically("~", time.Hour, 2*time.Second))
+	g.Expect(parseRetryAfter(time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat))).To(Equal(MinBackoff))
+}
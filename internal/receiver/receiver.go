@@ -0,0 +1,383 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package receiver
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-containerregistry/pkg/name"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// DistributionPath is the path receiving Docker Distribution
+	// notifications.
+	DistributionPath = "/hook/distribution"
+	// HarborPath is the path receiving Harbor webhook events.
+	HarborPath = "/hook/harbor"
+	// GHCRPath is the path receiving GitHub package events for GHCR.
+	GHCRPath = "/hook/ghcr"
+	// ECRPath is the path receiving ECR image actions shaped as EventBridge
+	// events.
+	ECRPath = "/hook/ecr"
+)
+
+// maxPayloadSize is the maximum size of the accepted payloads.
+const maxPayloadSize = 1 << 20
+
+// Scanner requests an immediate scan of the ImageRepositories with the given
+// canonical image name.
+type Scanner interface {
+	RequestScan(ctx context.Context, canonicalName string) ([]types.NamespacedName, error)
+}
+
+// Receiver is an HTTP server receiving push notifications from registries and
+// requesting a scan of the ImageRepositories of the pushed repositories.
+type Receiver struct {
+	// Addr is the address the server binds to.
+	Addr string
+	// Token must be given by the notifications in the Authorization header,
+	// either as is or as a bearer token. GitHub package events are verified
+	// with the X-Hub-Signature-256 header instead, using the token as the
+	// webhook secret. All the notifications are rejected when it's empty.
+	Token   string
+	Scanner Scanner
+	Logger  logr.Logger
+}
+
+// parseFunc parses a notification payload and returns the pushed
+// repositories, as `<registry>/<repository>`.
+type parseFunc func(header http.Header, body []byte) ([]string, error)
+
+// Handler returns the HTTP handler of the receiver.
+func (r *Receiver) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle(DistributionPath, r.handle(parseDistribution, r.verifyToken))
+	mux.Handle(HarborPath, r.handle(parseHarbor, r.verifyToken))
+	mux.Handle(GHCRPath, r.handle(parseGHCR, r.verifySignature))
+	mux.Handle(ECRPath, r.handle(parseECR, r.verifyToken))
+	return mux
+}
+
+// Start runs the server until the context is cancelled. It refuses to start
+// without token, for the notifications not to be unauthenticated.
+func (r *Receiver) Start(ctx context.Context) error {
+	if r.Token == "" {
+		return errors.New("the push notification receiver requires a token")
+	}
+	srv := &http.Server{
+		Addr:              r.Addr,
+		Handler:           r.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		r.Logger.Info("starting push notification receiver", "addr", r.Addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errCh <- err
+		}
+		close(errCh)
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return srv.Shutdown(shutdownCtx)
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable. The scan
+// requests are only processed by the leader.
+func (r *Receiver) NeedLeaderElection() bool {
+	return true
+}
+
+func (r *Receiver) handle(parse parseFunc, verify func(http.Header, []byte) bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(req.Body, maxPayloadSize))
+		if err != nil {
+			http.Error(w, "failed to read payload", http.StatusBadRequest)
+			return
+		}
+		if !verify(req.Header, body) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		repos, err := parse(req.Header, body)
+		if err != nil {
+			r.Logger.Error(err, "failed to parse push notification", "path", req.URL.Path)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		for _, repo := range dedup(repos) {
+			canonicalName, err := canonicalImageName(repo)
+			if err != nil {
+				r.Logger.Error(err, "invalid repository in push notification", "repository", repo)
+				continue
+			}
+			requested, err := r.Scanner.RequestScan(req.Context(), canonicalName)
+			if err != nil {
+				r.Logger.Error(err, "failed to request scan", "image", canonicalName)
+				http.Error(w, "failed to request scan", http.StatusInternalServerError)
+				return
+			}
+			for _, key := range requested {
+				r.Logger.V(1).Info("scan requested by push notification", "image", canonicalName, "imagerepository", key.String())
+			}
+		}
+		w.WriteHeader(http.StatusAccepted)
+	})
+}
+
+// verifyToken checks the Authorization header against the token. No request
+// is authorized without token.
+func (r *Receiver) verifyToken(header http.Header, _ []byte) bool {
+	if r.Token == "" {
+		return false
+	}
+	auth := strings.TrimPrefix(header.Get("Authorization"), "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(auth), []byte(r.Token)) == 1
+}
+
+// verifySignature checks the X-Hub-Signature-256 header of GitHub webhooks,
+// the HMAC of the body keyed with the token. No request is authorized without
+// token.
+func (r *Receiver) verifySignature(header http.Header, body []byte) bool {
+	if r.Token == "" {
+		return false
+	}
+	sig, ok := strings.CutPrefix(header.Get("X-Hub-Signature-256"), "sha256=")
+	if !ok {
+		return false
+	}
+	got, err := hex.DecodeString(sig)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(r.Token))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+// distributionNotification is the envelope of Docker Distribution
+// notifications.
+type distributionNotification struct {
+	Events []struct {
+		Action string `json:"action"`
+		Target struct {
+			Repository string `json:"repository"`
+			Tag        string `json:"tag"`
+			URL        string `json:"url"`
+		} `json:"target"`
+		Request struct {
+			Host string `json:"host"`
+		} `json:"request"`
+	} `json:"events"`
+}
+
+// parseDistribution returns the repositories of the tags pushed in a Docker
+// Distribution notification. The registry host is taken from the request
+// recorded in the event.
+func parseDistribution(_ http.Header, body []byte) ([]string, error) {
+	var n distributionNotification
+	if err := json.Unmarshal(body, &n); err != nil {
+		return nil, fmt.Errorf("failed to decode Docker Distribution notification: %w", err)
+	}
+	var repos []string
+	for _, e := range n.Events {
+		if e.Action != "push" || e.Target.Tag == "" {
+			continue
+		}
+		host := e.Request.Host
+		if host == "" {
+			host = hostFromURL(e.Target.URL)
+		}
+		if host == "" || e.Target.Repository == "" {
+			return nil, errors.New("push event without registry host or repository")
+		}
+		repos = append(repos, host+"/"+e.Target.Repository)
+	}
+	return repos, nil
+}
+
+// harborEvent is the payload of Harbor webhooks.
+type harborEvent struct {
+	Type      string `json:"type"`
+	EventData struct {
+		Resources []struct {
+			ResourceURL string `json:"resource_url"`
+		} `json:"resources"`
+	} `json:"event_data"`
+}
+
+// parseHarbor returns the repositories of the artifacts pushed in a Harbor
+// event, from their resource URLs.
+func parseHarbor(_ http.Header, body []byte) ([]string, error) {
+	var e harborEvent
+	if err := json.Unmarshal(body, &e); err != nil {
+		return nil, fmt.Errorf("failed to decode Harbor event: %w", err)
+	}
+	if e.Type != "PUSH_ARTIFACT" {
+		return nil, nil
+	}
+	var repos []string
+	for _, res := range e.EventData.Resources {
+		if res.ResourceURL == "" {
+			return nil, errors.New("pushed artifact without resource URL")
+		}
+		repos = append(repos, trimReference(res.ResourceURL))
+	}
+	return repos, nil
+}
+
+// githubPackageEvent is the payload of GitHub package events.
+type githubPackageEvent struct {
+	Action  string `json:"action"`
+	Package struct {
+		Name           string `json:"name"`
+		PackageType    string `json:"package_type"`
+		PackageVersion struct {
+			PackageURL string `json:"package_url"`
+		} `json:"package_version"`
+		Owner struct {
+			Login string `json:"login"`
+		} `json:"owner"`
+	} `json:"package"`
+}
+
+// parseGHCR returns the repository of the container package version published
+// in a GitHub package event. Other events and package types are ignored.
+func parseGHCR(header http.Header, body []byte) ([]string, error) {
+	if event := header.Get("X-GitHub-Event"); event != "" && event != "package" {
+		return nil, nil
+	}
+	var e githubPackageEvent
+	if err := json.Unmarshal(body, &e); err != nil {
+		return nil, fmt.Errorf("failed to decode GitHub package event: %w", err)
+	}
+	if e.Action != "published" || !strings.EqualFold(e.Package.PackageType, "container") {
+		return nil, nil
+	}
+	if u := e.Package.PackageVersion.PackageURL; u != "" {
+		return []string{trimReference(u)}, nil
+	}
+	if e.Package.Owner.Login == "" || e.Package.Name == "" {
+		return nil, errors.New("published package without owner or name")
+	}
+	return []string{strings.ToLower("ghcr.io/" + e.Package.Owner.Login + "/" + e.Package.Name)}, nil
+}
+
+// ecrEvent is an ECR image action as delivered by EventBridge.
+type ecrEvent struct {
+	Source  string `json:"source"`
+	Account string `json:"account"`
+	Region  string `json:"region"`
+	Detail  struct {
+		ActionType     string `json:"action-type"`
+		Result         string `json:"result"`
+		RepositoryName string `json:"repository-name"`
+		ImageTag       string `json:"image-tag"`
+	} `json:"detail"`
+}
+
+// parseECR returns the repository of the image pushed in an ECR image action.
+// The registry host is derived from the account and region of the event.
+func parseECR(_ http.Header, body []byte) ([]string, error) {
+	var e ecrEvent
+	if err := json.Unmarshal(body, &e); err != nil {
+		return nil, fmt.Errorf("failed to decode ECR event: %w", err)
+	}
+	if e.Source != "aws.ecr" || e.Detail.ActionType != "PUSH" || e.Detail.Result != "SUCCESS" || e.Detail.ImageTag == "" {
+		return nil, nil
+	}
+	if e.Account == "" || e.Region == "" || e.Detail.RepositoryName == "" {
+		return nil, errors.New("image action without account, region or repository")
+	}
+	host := fmt.Sprintf("%s.dkr.ecr.%s.amazonaws.com", e.Account, e.Region)
+	if strings.HasPrefix(e.Region, "cn-") {
+		host += ".cn"
+	}
+	return []string{host + "/" + e.Detail.RepositoryName}, nil
+}
+
+// trimReference removes the scheme, tag and digest from an image reference.
+func trimReference(ref string) string {
+	if _, rest, ok := strings.Cut(ref, "://"); ok {
+		ref = rest
+	}
+	if i := strings.Index(ref, "@"); i >= 0 {
+		ref = ref[:i]
+	}
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		ref = ref[:i]
+	}
+	return ref
+}
+
+// hostFromURL returns the host of the URL, or an empty string if it can't be
+// parsed.
+func hostFromURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+// canonicalImageName returns the name of the repository as recorded in the
+// status of the ImageRepositories.
+func canonicalImageName(repo string) (string, error) {
+	r, err := name.NewRepository(repo)
+	if err != nil {
+		return "", err
+	}
+	return r.String(), nil
+}
+
+func dedup(repos []string) []string {
+	seen := map[string]struct{}{}
+	var result []string
+	for _, r := range repos {
+		if _, ok := seen[r]; ok {
+			continue
+		}
+		seen[r] = struct{}{}
+		result = append(result, r)
+	}
+	return result
+}
@@ -0,0 +1,351 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package receiver
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+type fakeScanner struct {
+	requested []string
+	err       error
+}
+
+func (s *fakeScanner) RequestScan(_ context.Context, canonicalName string) ([]types.NamespacedName, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	s.requested = append(s.requested, canonicalName)
+	return []types.NamespacedName{{Namespace: "default", Name: "foo"}}, nil
+}
+
+const distributionPayload = `{
+  "events": [
+    {
+      "action": "push",
+      "target": {
+        "mediaType": "application/vnd.docker.distribution.manifest.v2+json",
+        "repository": "foo/bar",
+        "digest": "sha256:aaaa",
+        "tag": "1.0.0",
+        "url": "https://registry.example.com/v2/foo/bar/manifests/sha256:aaaa"
+      },
+      "request": {"host": "registry.example.com:5000"}
+    },
+    {
+      "action": "push",
+      "target": {
+        "repository": "foo/bar",
+        "digest": "sha256:bbbb",
+        "url": "https://registry.example.com/v2/foo/bar/manifests/sha256:bbbb"
+      },
+      "request": {"host": "registry.example.com:5000"}
+    },
+    {
+      "action": "pull",
+      "target": {"repository": "foo/baz", "tag": "1.0.0"},
+      "request": {"host": "registry.example.com:5000"}
+    },
+    {
+      "action": "push",
+      "target": {
+        "repository": "foo/qux",
+        "tag": "latest",
+        "url": "https://registry.example.com/v2/foo/qux/manifests/sha256:cccc"
+      }
+    }
+  ]
+}`
+
+const harborPayload = `{
+  "type": "PUSH_ARTIFACT",
+  "occur_at": 1680000000,
+  "operator": "admin",
+  "event_data": {
+    "resources": [
+      {
+        "digest": "sha256:aaaa",
+        "tag": "1.0.0",
+        "resource_url": "harbor.example.com/library/nginx:1.0.0"
+      }
+    ],
+    "repository": {
+      "name": "nginx",
+      "namespace": "library",
+      "repo_full_name": "library/nginx",
+      "repo_type": "private"
+    }
+  }
+}`
+
+const ghcrPayload = `{
+  "action": "published",
+  "package": {
+    "name": "podinfo",
+    "package_type": "CONTAINER",
+    "owner": {"login": "stefanprodan"},
+    "package_version": {
+      "version": "sha256:aaaa",
+      "container_metadata": {"tag": {"name": "6.3.5", "digest": "sha256:aaaa"}},
+      "package_url": "ghcr.io/stefanprodan/podinfo:6.3.5"
+    }
+  }
+}`
+
+const ecrPayload = `{
+  "version": "0",
+  "id": "13cde686-328b-6117-af20-0e5566167482",
+  "detail-type": "ECR Image Action",
+  "source": "aws.ecr",
+  "account": "123456789012",
+  "time": "2019-11-16T01:54:34Z",
+  "region": "us-west-2",
+  "detail": {
+    "result": "SUCCESS",
+    "repository-name": "my-repository-name",
+    "image-digest": "sha256:aaaa",
+    "action-type": "PUSH",
+    "image-tag": "latest"
+  }
+}`
+
+// testToken is the token of the receivers of the tests.
+const testToken = "s3cr3t"
+
+func TestReceiver(t *testing.T) {
+	tests := []struct {
+		name          string
+		path          string
+		method        string
+		header        map[string]string
+		payload       string
+		noToken       bool
+		scanErr       error
+		wantStatus    int
+		wantRequested []string
+	}{
+		{
+			name:          "distribution",
+			path:          DistributionPath,
+			payload:       distributionPayload,
+			wantStatus:    http.StatusAccepted,
+			wantRequested: []string{"registry.example.com:5000/foo/bar", "registry.example.com/foo/qux"},
+		},
+		{
+			name:          "harbor",
+			path:          HarborPath,
+			payload:       harborPayload,
+			wantStatus:    http.StatusAccepted,
+			wantRequested: []string{"harbor.example.com/library/nginx"},
+		},
+		{
+			name:       "harbor, other event",
+			path:       HarborPath,
+			payload:    strings.Replace(harborPayload, "PUSH_ARTIFACT", "DELETE_ARTIFACT", 1),
+			wantStatus: http.StatusAccepted,
+		},
+		{
+			name:          "ghcr",
+			path:          GHCRPath,
+			header:        map[string]string{"X-GitHub-Event": "package"},
+			payload:       ghcrPayload,
+			wantStatus:    http.StatusAccepted,
+			wantRequested: []string{"ghcr.io/stefanprodan/podinfo"},
+		},
+		{
+			name:          "ghcr, without package URL",
+			path:          GHCRPath,
+			payload:       strings.Replace(ghcrPayload, `"package_url": "ghcr.io/stefanprodan/podinfo:6.3.5"`, `"package_url": ""`, 1),
+			wantStatus:    http.StatusAccepted,
+			wantRequested: []string{"ghcr.io/stefanprodan/podinfo"},
+		},
+		{
+			name:       "ghcr, other event",
+			path:       GHCRPath,
+			header:     map[string]string{"X-GitHub-Event": "ping"},
+			payload:    `{"zen": "Keep it logically awesome."}`,
+			wantStatus: http.StatusAccepted,
+		},
+		{
+			name:          "ecr",
+			path:          ECRPath,
+			payload:       ecrPayload,
+			wantStatus:    http.StatusAccepted,
+			wantRequested: []string{"123456789012.dkr.ecr.us-west-2.amazonaws.com/my-repository-name"},
+		},
+		{
+			name:       "ecr, failed push",
+			path:       ECRPath,
+			payload:    strings.Replace(ecrPayload, "SUCCESS", "FAILURE", 1),
+			wantStatus: http.StatusAccepted,
+		},
+		{
+			name:       "invalid payload",
+			path:       DistributionPath,
+			payload:    `{"events": `,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "wrong method",
+			path:       DistributionPath,
+			method:     http.MethodGet,
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			name:       "unknown path",
+			path:       "/hook/unknown",
+			payload:    distributionPayload,
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "scan request fails",
+			path:       ECRPath,
+			payload:    ecrPayload,
+			scanErr:    errors.New("fail"),
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name:       "without token",
+			path:       HarborPath,
+			header:     map[string]string{"Authorization": ""},
+			payload:    harborPayload,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "without signature",
+			path:       GHCRPath,
+			header:     map[string]string{"X-Hub-Signature-256": ""},
+			payload:    ghcrPayload,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "no token configured",
+			path:       DistributionPath,
+			payload:    distributionPayload,
+			noToken:    true,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "no token configured, ghcr",
+			path:       GHCRPath,
+			payload:    ghcrPayload,
+			noToken:    true,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:          "with token",
+			path:          HarborPath,
+			header:        map[string]string{"Authorization": "s3cr3t"},
+			payload:       harborPayload,
+			wantStatus:    http.StatusAccepted,
+			wantRequested: []string{"harbor.example.com/library/nginx"},
+		},
+		{
+			name:          "with bearer token",
+			path:          DistributionPath,
+			header:        map[string]string{"Authorization": "Bearer s3cr3t"},
+			payload:       distributionPayload,
+			wantStatus:    http.StatusAccepted,
+			wantRequested: []string{"registry.example.com:5000/foo/bar", "registry.example.com/foo/qux"},
+		},
+		{
+			name:       "with wrong token",
+			path:       ECRPath,
+			header:     map[string]string{"Authorization": "Bearer wrong"},
+			payload:    ecrPayload,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:          "with signature",
+			path:          GHCRPath,
+			header:        map[string]string{"X-Hub-Signature-256": "sha256=" + sign("s3cr3t", ghcrPayload)},
+			payload:       ghcrPayload,
+			wantStatus:    http.StatusAccepted,
+			wantRequested: []string{"ghcr.io/stefanprodan/podinfo"},
+		},
+		{
+			name:       "with wrong signature",
+			path:       GHCRPath,
+			header:     map[string]string{"X-Hub-Signature-256": "sha256=" + sign("wrong", ghcrPayload)},
+			payload:    ghcrPayload,
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			scanner := &fakeScanner{err: tt.scanErr}
+			r := &Receiver{
+				Token:   testToken,
+				Scanner: scanner,
+				Logger:  logr.Discard(),
+			}
+			if tt.noToken {
+				r.Token = ""
+			}
+
+			method := tt.method
+			if method == "" {
+				method = http.MethodPost
+			}
+			req := httptest.NewRequest(method, tt.path, strings.NewReader(tt.payload))
+			// The notifications are authenticated unless the test case
+			// overrides the headers.
+			req.Header.Set("Authorization", "Bearer "+testToken)
+			req.Header.Set("X-Hub-Signature-256", "sha256="+sign(testToken, tt.payload))
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			r.Handler().ServeHTTP(rec, req)
+
+			g.Expect(rec.Code).To(Equal(tt.wantStatus))
+			g.Expect(scanner.requested).To(Equal(tt.wantRequested))
+		})
+	}
+}
+
+func TestCanonicalImageName(t *testing.T) {
+	g := NewWithT(t)
+
+	name, err := canonicalImageName("docker.io/library/alpine")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(name).To(Equal("index.docker.io/library/alpine"))
+
+	_, err = canonicalImageName("example.com/Foo")
+	g.Expect(err).To(HaveOccurred())
+}
+
+func sign(key, payload string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
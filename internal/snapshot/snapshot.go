@@ -0,0 +1,143 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package snapshot
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/fluxcd/image-reflector-controller/internal/database"
+)
+
+// DataKey is the key of the ConfigMap binary data holding the snapshot.
+const DataKey = "snapshot.json.gz"
+
+// maxSize is the maximum size of a compressed snapshot, leaving room for the
+// metadata of the ConfigMap under the 1MiB size limit of the objects.
+const maxSize = 1000 * 1024
+
+// finalSaveTimeout is the time given to the snapshot taken when the
+// controller stops.
+const finalSaveTimeout = 10 * time.Second
+
+// Snapshotter periodically saves a snapshot of the database to a ConfigMap,
+// for the database to be restored from it when the controller starts with an
+// empty database, e.g. after its volume was lost.
+type Snapshotter struct {
+	Client client.Client
+	// APIReader reads the ConfigMap, bypassing the cache of the manager.
+	APIReader client.Reader
+	Database  database.Database
+	// ConfigMap is the name of the ConfigMap holding the snapshot.
+	ConfigMap types.NamespacedName
+	// Interval is the time between two snapshots.
+	Interval time.Duration
+	Logger   logr.Logger
+}
+
+// Start saves a snapshot every interval until the context is cancelled, and
+// a last one then.
+func (s *Snapshotter) Start(ctx context.Context) error {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			saveCtx, cancel := context.WithTimeout(context.Background(), finalSaveTimeout)
+			defer cancel()
+			if err := s.Save(saveCtx); err != nil {
+				s.Logger.Error(err, "failed to save the database snapshot")
+			}
+			return nil
+		case <-ticker.C:
+			if err := s.Save(ctx); err != nil {
+				s.Logger.Error(err, "failed to save the database snapshot")
+			}
+		}
+	}
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable. Only the
+// leader writes to the database, and the snapshot.
+func (s *Snapshotter) NeedLeaderElection() bool {
+	return true
+}
+
+// Save writes a snapshot of the database to the ConfigMap, creating it if
+// needed.
+func (s *Snapshotter) Save(ctx context.Context) error {
+	var buf bytes.Buffer
+	if err := database.Export(s.Database, &buf); err != nil {
+		return fmt.Errorf("failed to export database: %w", err)
+	}
+	if buf.Len() > maxSize {
+		return fmt.Errorf("snapshot size %d bytes exceeds the limit of %d bytes", buf.Len(), maxSize)
+	}
+
+	var cm corev1.ConfigMap
+	err := s.APIReader.Get(ctx, s.ConfigMap, &cm)
+	if apierrors.IsNotFound(err) {
+		cm = corev1.ConfigMap{}
+		cm.Name = s.ConfigMap.Name
+		cm.Namespace = s.ConfigMap.Namespace
+		cm.BinaryData = map[string][]byte{DataKey: buf.Bytes()}
+		return s.Client.Create(ctx, &cm)
+	}
+	if err != nil {
+		return err
+	}
+	cm.BinaryData = map[string][]byte{DataKey: buf.Bytes()}
+	return s.Client.Update(ctx, &cm)
+}
+
+// Restore imports the snapshot of the ConfigMap into the database, and returns
+// whether a snapshot was restored. A database that isn't empty, e.g. shared
+// by the replicas of the controller, is left untouched. It's a no-op when the
+// ConfigMap doesn't exist.
+func (s *Snapshotter) Restore(ctx context.Context) (bool, error) {
+	repos, err := s.Database.Repositories()
+	if err != nil {
+		return false, fmt.Errorf("failed to list images in database: %w", err)
+	}
+	if len(repos) > 0 {
+		return false, nil
+	}
+
+	var cm corev1.ConfigMap
+	if err := s.APIReader.Get(ctx, s.ConfigMap, &cm); err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	data, ok := cm.BinaryData[DataKey]
+	if !ok {
+		return false, fmt.Errorf("ConfigMap %s has no %s key", s.ConfigMap, DataKey)
+	}
+	if err := database.Import(s.Database, bytes.NewReader(data)); err != nil {
+		return false, fmt.Errorf("failed to import snapshot: %w", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,132 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package snapshot
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/fluxcd/image-reflector-controller/internal/database"
+)
+
+const testRepo = "example.com/foo/bar"
+
+func TestSnapshotter_SaveRestore(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.TODO()
+	c := fake.NewClientBuilder().Build()
+	name := types.NamespacedName{Namespace: "flux-system", Name: "image-reflector-snapshot"}
+
+	src := database.NewMemoryDatabase()
+	g.Expect(src.SetTags(testRepo, []string{"v1", "v2"})).To(Succeed())
+	s := &Snapshotter{Client: c, APIReader: c, Database: src, ConfigMap: name, Logger: logr.Discard()}
+
+	// Saving creates the ConfigMap, and then updates it.
+	g.Expect(s.Save(ctx)).To(Succeed())
+	g.Expect(src.SetTags(testRepo, []string{"v1", "v2", "v3"})).To(Succeed())
+	g.Expect(s.Save(ctx)).To(Succeed())
+	var cm corev1.ConfigMap
+	g.Expect(c.Get(ctx, name, &cm)).To(Succeed())
+	g.Expect(cm.BinaryData).To(HaveKey(DataKey))
+
+	dst := database.NewMemoryDatabase()
+	s = &Snapshotter{Client: c, APIReader: c, Database: dst, ConfigMap: name, Logger: logr.Discard()}
+	restored, err := s.Restore(ctx)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(restored).To(BeTrue())
+	g.Expect(dst.Tags(testRepo)).To(Equal([]string{"v1", "v2", "v3"}))
+}
+
+func TestSnapshotter_Restore(t *testing.T) {
+	name := types.NamespacedName{Namespace: "flux-system", Name: "image-reflector-snapshot"}
+	snapshot := func(g *WithT) *corev1.ConfigMap {
+		db := database.NewMemoryDatabase()
+		g.Expect(db.SetTags(testRepo, []string{"v1"})).To(Succeed())
+		c := fake.NewClientBuilder().Build()
+		s := &Snapshotter{Client: c, APIReader: c, Database: db, ConfigMap: name}
+		g.Expect(s.Save(context.TODO())).To(Succeed())
+		var cm corev1.ConfigMap
+		g.Expect(c.Get(context.TODO(), name, &cm)).To(Succeed())
+		cm.ResourceVersion = ""
+		return &cm
+	}
+
+	tests := []struct {
+		name         string
+		configMap    func(g *WithT) *corev1.ConfigMap
+		existingTags []string
+		wantRestored bool
+		wantErr      bool
+		wantTags     []string
+	}{
+		{
+			name:         "restores empty database",
+			configMap:    snapshot,
+			wantRestored: true,
+			wantTags:     []string{"v1"},
+		},
+		{
+			name:         "skips database with data",
+			configMap:    snapshot,
+			existingTags: []string{"v2"},
+			wantTags:     []string{"v2"},
+		},
+		{
+			name:     "no ConfigMap",
+			wantTags: []string{},
+		},
+		{
+			name: "ConfigMap without snapshot",
+			configMap: func(g *WithT) *corev1.ConfigMap {
+				cm := &corev1.ConfigMap{}
+				cm.Name = name.Name
+				cm.Namespace = name.Namespace
+				return cm
+			},
+			wantErr:  true,
+			wantTags: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			builder := fake.NewClientBuilder()
+			if tt.configMap != nil {
+				builder.WithObjects(tt.configMap(g))
+			}
+			c := builder.Build()
+			db := database.NewMemoryDatabase()
+			if tt.existingTags != nil {
+				g.Expect(db.SetTags(testRepo, tt.existingTags)).To(Succeed())
+			}
+			s := &Snapshotter{Client: c, APIReader: c, Database: db, ConfigMap: name}
+
+			restored, err := s.Restore(context.TODO())
+			g.Expect(err != nil).To(Equal(tt.wantErr))
+			g.Expect(restored).To(Equal(tt.wantRestored))
+			g.Expect(db.Tags(testRepo)).To(Equal(tt.wantTags))
+		})
+	}
+}
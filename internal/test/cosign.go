@@ -0,0 +1,103 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package test
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// CosignPayload returns the simple signing payload cosign signs for the image
+// with the given digest.
+func CosignPayload(digest name.Digest) []byte {
+	payload := map[string]interface{}{
+		"critical": map[string]interface{}{
+			"identity": map[string]string{"docker-reference": digest.Context().String()},
+			"image":    map[string]string{"docker-manifest-digest": digest.DigestStr()},
+			"type":     "cosign container image signature",
+		},
+		"optional": nil,
+	}
+	b, _ := json.Marshal(payload)
+	return b
+}
+
+// WriteCosignSignature pushes a cosign signature image for the image with the
+// given digest, with a layer holding the given payload and annotations.
+func WriteCosignSignature(digest name.Digest, payload []byte, annotations map[string]string, options ...remote.Option) error {
+	layer := static.NewLayer(payload, "application/vnd.dev.cosign.simplesigning.v1+json")
+	img, err := mutate.Append(mutate.MediaType(empty.Image, types.OCIManifestSchema1), mutate.Addendum{
+		Layer:       layer,
+		Annotations: annotations,
+	})
+	if err != nil {
+		return err
+	}
+	hash, err := digestHash(digest)
+	if err != nil {
+		return err
+	}
+	return remote.Write(digest.Context().Tag(hash+".sig"), img, options...)
+}
+
+// SignCosign signs the image with the given digest with the given ECDSA key,
+// and pushes the signature as cosign does.
+func SignCosign(digest name.Digest, key *ecdsa.PrivateKey, options ...remote.Option) error {
+	payload := CosignPayload(digest)
+	h := sha256.Sum256(payload)
+	sig, err := ecdsa.SignASN1(rand.Reader, key, h[:])
+	if err != nil {
+		return err
+	}
+	return WriteCosignSignature(digest, payload, map[string]string{
+		"dev.cosignproject.cosign/signature": base64.StdEncoding.EncodeToString(sig),
+	}, options...)
+}
+
+// PublicKeyPEM returns the PEM encoding of the public key of the given ECDSA
+// key, as in cosign.pub files.
+func PublicKeyPEM(key *ecdsa.PrivateKey) ([]byte, error) {
+	b, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: b}), nil
+}
+
+// digestHash returns the digest in the form used in the tags of cosign
+// signatures, i.e. `sha256-<hex>`.
+func digestHash(digest name.Digest) (string, error) {
+	algo, hex, ok := strings.Cut(digest.DigestStr(), ":")
+	if !ok {
+		return "", fmt.Errorf("invalid digest '%s'", digest.DigestStr())
+	}
+	return algo + "-" + hex, nil
+}
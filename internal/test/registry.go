@@ -17,12 +17,17 @@ limitations under the License.
 package test
 
 import (
+	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/google/go-containerregistry/pkg/name"
@@ -117,6 +122,20 @@ func (h *TagListHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if withoutTagsList := strings.TrimSuffix(r.URL.Path, "/tags/list"); r.Method == "GET" && withoutTagsList != r.URL.Path {
 		repo := strings.TrimPrefix(withoutTagsList, "/v2/")
 		if tags, ok := h.Imagetags[repo]; ok {
+			tags, next := paginate(tags, r.URL.Query())
+			if next != "" {
+				w.Header().Set("Link", fmt.Sprintf(`<%s?%s>; rel="next"`, r.URL.Path, next))
+			}
+			// A response with all the tags is tagged with an ETag, and can be
+			// requested conditionally.
+			if next == "" && r.URL.Query().Get("last") == "" {
+				etag := tagsETag(tags)
+				w.Header().Set("ETag", etag)
+				if r.Header.Get("If-None-Match") == etag {
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+			}
 			w.Header().Set("Content-Type", "application/json")
 			w.WriteHeader(http.StatusOK)
 			result := TagListResult{
@@ -137,7 +156,10 @@ func (h *TagListHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	h.RegistryHandler.ServeHTTP(w, r)
 	if r.Method == "PUT" {
 		pathElements := strings.Split(r.URL.Path, "/")
-		if len(pathElements) == 5 && pathElements[1] == "v2" && pathElements[3] == "manifests" {
+		// Manifests pushed by digest, e.g. the manifests of an image index,
+		// are not tagged.
+		if len(pathElements) == 5 && pathElements[1] == "v2" && pathElements[3] == "manifests" &&
+			!strings.Contains(pathElements[4], ":") {
 			repo, tag := pathElements[2], pathElements[4]
 			println("Recording tag", repo, tag)
 			h.Imagetags[repo] = append(h.Imagetags[repo], tag)
@@ -145,6 +167,45 @@ func (h *TagListHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// tagsETag returns an ETag for the given list of tags.
+func tagsETag(tags []string) string {
+	h := sha256.New()
+	for _, tag := range tags {
+		fmt.Fprintln(h, tag)
+	}
+	return fmt.Sprintf(`"%x"`, h.Sum(nil))
+}
+
+// paginate returns the page of tags requested with the `n` and `last` query
+// parameters, along with the query of the next page if there's one. Tags are
+// paginated in lexical order, as per the distribution spec.
+func paginate(tags []string, query url.Values) ([]string, string) {
+	n, _ := strconv.Atoi(query.Get("n"))
+	last := query.Get("last")
+	if n <= 0 && last == "" {
+		return tags, ""
+	}
+
+	sorted := append([]string{}, tags...)
+	sort.Strings(sorted)
+	if last != "" {
+		i := sort.SearchStrings(sorted, last)
+		if i < len(sorted) && sorted[i] == last {
+			i++
+		}
+		sorted = sorted[i:]
+	}
+	if n <= 0 || len(sorted) <= n {
+		return sorted, ""
+	}
+
+	page := sorted[:n]
+	next := url.Values{}
+	next.Set("n", strconv.Itoa(n))
+	next.Set("last", page[len(page)-1])
+	return page, next.Encode()
+}
+
 // there's no authentication in go-containerregistry/pkg/registry;
 // this wrapper adds basic auth to a registry handler. NB: the
 // important thing is to be able to test that the credentials get from
@@ -17,6 +17,9 @@ limitations under the License.
 package test
 
 import (
+	"context"
+	"net/http"
+	"strings"
 	"testing"
 
 	"github.com/google/go-containerregistry/pkg/authn"
@@ -41,6 +44,65 @@ func TestRegistryHandler(t *testing.T) {
 	g.Expect(tags).To(Equal(uploadedTags))
 }
 
+func TestRegistryHandlerPagination(t *testing.T) {
+	g := NewWithT(t)
+
+	srv := NewRegistryServer()
+	defer srv.Close()
+
+	uploadedTags := []string{"tag3", "tag1", "tag2"}
+	repoString, err := LoadImages(srv, "testpaginated", uploadedTags)
+	g.Expect(err).ToNot(HaveOccurred())
+	repo, _ := name.NewRepository(repoString)
+
+	puller, err := remote.NewPuller(remote.WithPageSize(2))
+	g.Expect(err).ToNot(HaveOccurred())
+	lister, err := puller.Lister(context.TODO(), repo)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	var pages [][]string
+	for lister.HasNext() {
+		page, err := lister.Next(context.TODO())
+		g.Expect(err).ToNot(HaveOccurred())
+		pages = append(pages, page.Tags)
+	}
+	g.Expect(pages).To(Equal([][]string{{"tag1", "tag2"}, {"tag3"}}))
+}
+
+func TestRegistryHandlerETag(t *testing.T) {
+	g := NewWithT(t)
+
+	srv := NewRegistryServer()
+	defer srv.Close()
+
+	repoString, err := LoadImages(srv, "testetag", []string{"tag1", "tag2"})
+	g.Expect(err).ToNot(HaveOccurred())
+	u := srv.URL + "/v2/" + strings.TrimPrefix(repoString, RegistryName(srv)+"/") + "/tags/list"
+
+	resp, err := http.Get(u)
+	g.Expect(err).ToNot(HaveOccurred())
+	resp.Body.Close()
+	g.Expect(resp.StatusCode).To(Equal(http.StatusOK))
+	etag := resp.Header.Get("ETag")
+	g.Expect(etag).ToNot(BeEmpty())
+
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	req.Header.Set("If-None-Match", etag)
+	resp, err = http.DefaultClient.Do(req)
+	g.Expect(err).ToNot(HaveOccurred())
+	resp.Body.Close()
+	g.Expect(resp.StatusCode).To(Equal(http.StatusNotModified))
+
+	_, err = LoadImages(srv, "testetag", []string{"tag3"})
+	g.Expect(err).ToNot(HaveOccurred())
+	resp, err = http.DefaultClient.Do(req)
+	g.Expect(err).ToNot(HaveOccurred())
+	resp.Body.Close()
+	g.Expect(resp.StatusCode).To(Equal(http.StatusOK))
+	g.Expect(resp.Header.Get("ETag")).ToNot(Equal(etag))
+}
+
 func TestAuthenticationHandler(t *testing.T) {
 	username, password := "user", "password1"
 
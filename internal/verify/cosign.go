@@ -0,0 +1,188 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package verify
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/sigstore/cosign/v2/pkg/cosign"
+	ociremote "github.com/sigstore/cosign/v2/pkg/oci/remote"
+	"github.com/sigstore/sigstore/pkg/signature"
+	"github.com/sigstore/sigstore/pkg/tuf"
+)
+
+// ErrNoValidSignature is returned when an image has no signature, or none of
+// its signatures could be verified.
+var ErrNoValidSignature = errors.New("no valid signature")
+
+// TrustedRoot is the trust material of keyless signatures.
+type TrustedRoot struct {
+	// FulcioRoots are the root certificates the signing certificates must
+	// chain to.
+	FulcioRoots *x509.CertPool
+	// RekorKeys are the public keys of the Rekor transparency logs, used to
+	// verify the time the signatures were logged at.
+	RekorKeys []crypto.PublicKey
+}
+
+// LoadTrustedRoot reads the PEM encoded Fulcio root certificates and Rekor
+// public keys from the files at the given paths.
+func LoadTrustedRoot(fulcioRootsPath, rekorKeysPath string) (*TrustedRoot, error) {
+	b, err := os.ReadFile(fulcioRootsPath)
+	if err != nil {
+		return nil, err
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(b) {
+		return nil, fmt.Errorf("no certificate found in %q", fulcioRootsPath)
+	}
+
+	b, err = os.ReadFile(rekorKeysPath)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := ParsePublicKeys(b)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Rekor public keys %q: %w", rekorKeysPath, err)
+	}
+	return &TrustedRoot{FulcioRoots: roots, RekorKeys: keys}, nil
+}
+
+// ParsePublicKeys parses the PEM encoded public keys in the given data.
+func ParsePublicKeys(data []byte) ([]crypto.PublicKey, error) {
+	var keys []crypto.PublicKey
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no PEM encoded public key found")
+	}
+	return keys, nil
+}
+
+// CosignVerifier verifies the cosign signatures of images, stored in the same
+// repository as the images under the `sha256-<hex>.sig` tag.
+type CosignVerifier struct {
+	// checkOpts holds the options of each way a signature can be verified,
+	// i.e. one per public key, or a single one for keyless signatures.
+	checkOpts []*cosign.CheckOpts
+	options   []remote.Option
+}
+
+// NewKeyVerifier returns a CosignVerifier accepting the signatures made with
+// the private key of one of the given public keys.
+func NewKeyVerifier(keys []crypto.PublicKey, options ...remote.Option) (*CosignVerifier, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("no public keys to verify signatures with")
+	}
+	v := &CosignVerifier{options: options}
+	for _, key := range keys {
+		sigVerifier, err := signature.LoadVerifier(key, crypto.SHA256)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load public key: %w", err)
+		}
+		v.checkOpts = append(v.checkOpts, &cosign.CheckOpts{
+			ClaimVerifier: cosign.SimpleClaimVerifier,
+			SigVerifier:   sigVerifier,
+			// Signatures made with a key are not required to be logged in
+			// Rekor.
+			IgnoreTlog: true,
+			Offline:    true,
+		})
+	}
+	return v, nil
+}
+
+// NewKeylessVerifier returns a CosignVerifier accepting the keyless signatures
+// made with a certificate chaining to the Fulcio roots, logged in Rekor while
+// the certificate was valid, and issued for one of the given identities, of
+// which there must be at least one.
+func NewKeylessVerifier(root *TrustedRoot, identities []cosign.Identity, options ...remote.Option) (*CosignVerifier, error) {
+	if root == nil || root.FulcioRoots == nil || len(root.RekorKeys) == 0 {
+		return nil, errors.New("keyless verification requires Fulcio roots and Rekor public keys")
+	}
+	if len(identities) == 0 {
+		return nil, errors.New("keyless verification requires at least one identity")
+	}
+	rekorKeys := cosign.NewTrustedTransparencyLogPubKeys()
+	for _, key := range root.RekorKeys {
+		logID, err := cosign.GetTransparencyLogID(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Rekor public key: %w", err)
+		}
+		rekorKeys.Keys[logID] = cosign.TransparencyLogPubKey{PubKey: key, Status: tuf.Active}
+	}
+	return &CosignVerifier{
+		checkOpts: []*cosign.CheckOpts{{
+			ClaimVerifier: cosign.SimpleClaimVerifier,
+			RootCerts:     root.FulcioRoots,
+			RekorPubKeys:  &rekorKeys,
+			Identities:    identities,
+			// The signatures are verified against the Rekor bundles in their
+			// annotations, without querying Rekor.
+			Offline: true,
+			// The trust material holds no certificate transparency log keys
+			// to verify the SCTs of the certificates with.
+			IgnoreSCT: true,
+		}},
+		options: options,
+	}, nil
+}
+
+// Verify verifies the signatures of the image the given reference points at.
+// The error wraps ErrNoValidSignature if the image has no signature, or none
+// of its signatures is valid.
+func (v *CosignVerifier) Verify(ctx context.Context, ref name.Reference) error {
+	opts := append([]remote.Option{}, v.options...)
+	opts = append(opts, remote.WithContext(ctx))
+
+	var errs []error
+	for _, checkOpts := range v.checkOpts {
+		// cosign caches the intermediate certificates of the first signature
+		// in the options, which must not leak to the next images.
+		co := *checkOpts
+		co.RegistryClientOpts = []ociremote.Option{ociremote.WithRemoteOptions(opts...)}
+		_, _, err := cosign.VerifyImageSignatures(ctx, ref, &co)
+		if err == nil {
+			return nil
+		}
+		var noMatch *cosign.ErrNoMatchingSignatures
+		var noSignatures *cosign.ErrNoSignaturesFound
+		if !errors.As(err, &noMatch) && !errors.As(err, &noSignatures) {
+			return err
+		}
+		errs = append(errs, err)
+	}
+	return fmt.Errorf("%w for '%s': %w", ErrNoValidSignature, ref, errors.Join(errs...))
+}
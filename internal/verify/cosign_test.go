@@ -0,0 +1,381 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package verify
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	. "github.com/onsi/gomega"
+	"github.com/sigstore/cosign/v2/pkg/cosign"
+	"github.com/sigstore/cosign/v2/pkg/oci/static"
+
+	"github.com/fluxcd/image-reflector-controller/internal/test"
+)
+
+func TestCosignVerifier_key(t *testing.T) {
+	g := NewWithT(t)
+
+	srv := test.NewRegistryServer()
+	defer srv.Close()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	g.Expect(err).ToNot(HaveOccurred())
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	signed := pushImage(t, srv.URL, "signed")
+	g.Expect(test.SignCosign(signed, key)).To(Succeed())
+	otherSigned := pushImage(t, srv.URL, "other-signed")
+	g.Expect(test.SignCosign(otherSigned, otherKey)).To(Succeed())
+	unsigned := pushImage(t, srv.URL, "unsigned")
+	// A valid signature of another image.
+	replayed := pushImage(t, srv.URL, "replayed")
+	payload := test.CosignPayload(signed)
+	h := sha256.Sum256(payload)
+	sig, err := ecdsa.SignASN1(rand.Reader, key, h[:])
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(test.WriteCosignSignature(replayed, payload, map[string]string{
+		static.SignatureAnnotationKey: base64.StdEncoding.EncodeToString(sig),
+	})).To(Succeed())
+
+	pub, err := test.PublicKeyPEM(key)
+	g.Expect(err).ToNot(HaveOccurred())
+	keys, err := ParsePublicKeys(pub)
+	g.Expect(err).ToNot(HaveOccurred())
+	v, err := NewKeyVerifier(keys)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	g.Expect(v.Verify(context.TODO(), signed)).To(Succeed())
+	g.Expect(v.Verify(context.TODO(), signed.Context().Tag("signed"))).To(Succeed())
+
+	for _, ref := range []name.Reference{otherSigned, unsigned, replayed} {
+		err = v.Verify(context.TODO(), ref)
+		g.Expect(errors.Is(err, ErrNoValidSignature)).To(BeTrue(), "%s: %v", ref, err)
+	}
+
+	// Registry errors are not verification failures.
+	err = v.Verify(context.TODO(), signed.Context().Tag("missing"))
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(errors.Is(err, ErrNoValidSignature)).To(BeFalse())
+}
+
+func TestCosignVerifier_keyless(t *testing.T) {
+	g := NewWithT(t)
+
+	srv := test.NewRegistryServer()
+	defer srv.Close()
+
+	fulcio := newFulcio(t)
+	rekorKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	g.Expect(err).ToNot(HaveOccurred())
+	root := &TrustedRoot{FulcioRoots: x509.NewCertPool(), RekorKeys: []crypto.PublicKey{&rekorKey.PublicKey}}
+	root.FulcioRoots.AddCert(fulcio.cert)
+
+	const (
+		issuer  = "https://token.actions.githubusercontent.com"
+		subject = "https://github.com/org/repo/.github/workflows/release.yaml@refs/heads/main"
+	)
+	signed := pushImage(t, srv.URL, "signed")
+	g.Expect(fulcio.sign(signed, issuer, subject, rekorKey, nil)).To(Succeed())
+	// A bundle tampered with to fake the time of signing.
+	tampered := pushImage(t, srv.URL, "tampered")
+	g.Expect(fulcio.sign(tampered, issuer, subject, rekorKey, func(b map[string]interface{}) {
+		b["Payload"].(map[string]interface{})["integratedTime"] = time.Now().Unix()
+	})).To(Succeed())
+	// A bundle signed by another Rekor instance.
+	otherRekorKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	g.Expect(err).ToNot(HaveOccurred())
+	otherRekor := pushImage(t, srv.URL, "other-rekor")
+	g.Expect(fulcio.sign(otherRekor, issuer, subject, otherRekorKey, nil)).To(Succeed())
+	// A valid signature of another identity, e.g. of a fork of the repo.
+	otherIdentity := pushImage(t, srv.URL, "other-identity")
+	g.Expect(fulcio.sign(otherIdentity, issuer, "https://github.com/fork/repo/.github/workflows/release.yaml@refs/heads/main", rekorKey, nil)).To(Succeed())
+
+	repoIdentity := []cosign.Identity{
+		{IssuerRegExp: "^https://token.actions.githubusercontent.com$", SubjectRegExp: "^https://github.com/org/repo/"},
+	}
+
+	tests := []struct {
+		name       string
+		ref        name.Reference
+		identities []cosign.Identity
+		wantErr    bool
+	}{
+		{
+			name:       "identity",
+			ref:        signed,
+			identities: repoIdentity,
+		},
+		{
+			name: "matching identity",
+			ref:  signed,
+			identities: []cosign.Identity{
+				{IssuerRegExp: "^https://accounts.google.com$", SubjectRegExp: ".*"},
+				{IssuerRegExp: "^https://token.actions.githubusercontent.com$", SubjectRegExp: "^https://github.com/org/repo/"},
+			},
+		},
+		{
+			name: "other subject",
+			ref:  signed,
+			identities: []cosign.Identity{
+				{IssuerRegExp: ".*", SubjectRegExp: "^https://github.com/org/other/"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "other issuer",
+			ref:  signed,
+			identities: []cosign.Identity{
+				{IssuerRegExp: "^https://accounts.google.com$", SubjectRegExp: ".*"},
+			},
+			wantErr: true,
+		},
+		{
+			name:       "signed by another identity",
+			ref:        otherIdentity,
+			identities: repoIdentity,
+			wantErr:    true,
+		},
+		{
+			name:       "tampered bundle",
+			ref:        tampered,
+			identities: repoIdentity,
+			wantErr:    true,
+		},
+		{
+			name:       "other Rekor",
+			ref:        otherRekor,
+			identities: repoIdentity,
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			v, err := NewKeylessVerifier(root, tt.identities)
+			g.Expect(err).ToNot(HaveOccurred())
+			err = v.Verify(context.TODO(), tt.ref)
+			if tt.wantErr {
+				g.Expect(errors.Is(err, ErrNoValidSignature)).To(BeTrue(), "%v", err)
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+		})
+	}
+
+	// Signatures made with a certificate of another CA are not trusted.
+	other := &TrustedRoot{FulcioRoots: x509.NewCertPool(), RekorKeys: root.RekorKeys}
+	other.FulcioRoots.AddCert(newFulcio(t).cert)
+	v, err := NewKeylessVerifier(other, repoIdentity)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(errors.Is(v.Verify(context.TODO(), signed), ErrNoValidSignature)).To(BeTrue())
+
+	_, err = NewKeylessVerifier(nil, repoIdentity)
+	g.Expect(err).To(HaveOccurred())
+	// The signatures of any identity are not trusted.
+	_, err = NewKeylessVerifier(root, nil)
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestLoadTrustedRoot(t *testing.T) {
+	g := NewWithT(t)
+
+	fulcio := newFulcio(t)
+	rekorKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	g.Expect(err).ToNot(HaveOccurred())
+	rekorPub, err := test.PublicKeyPEM(rekorKey)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	dir := t.TempDir()
+	rootsPath := filepath.Join(dir, "fulcio.pem")
+	keysPath := filepath.Join(dir, "rekor.pub")
+	g.Expect(os.WriteFile(rootsPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: fulcio.cert.Raw}), 0o600)).To(Succeed())
+	g.Expect(os.WriteFile(keysPath, rekorPub, 0o600)).To(Succeed())
+
+	root, err := LoadTrustedRoot(rootsPath, keysPath)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(root.RekorKeys).To(HaveLen(1))
+
+	_, err = LoadTrustedRoot(keysPath, keysPath)
+	g.Expect(err).To(HaveOccurred())
+	_, err = LoadTrustedRoot(rootsPath, rootsPath)
+	g.Expect(err).To(HaveOccurred())
+}
+
+// pushImage pushes a random image with the given tag and returns its digest.
+func pushImage(t *testing.T, registryURL, tag string) name.Digest {
+	t.Helper()
+	g := NewWithT(t)
+
+	u, err := url.Parse(registryURL)
+	g.Expect(err).ToNot(HaveOccurred())
+	ref, err := name.NewTag(u.Host + "/verify:" + tag)
+	g.Expect(err).ToNot(HaveOccurred())
+	img, err := random.Image(512, 1)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(remote.Write(ref, img)).To(Succeed())
+	digest, err := img.Digest()
+	g.Expect(err).ToNot(HaveOccurred())
+	return ref.Context().Digest(digest.String())
+}
+
+// fulcio is a certificate authority issuing short-lived signing certificates.
+type fulcio struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newFulcio(t *testing.T) *fulcio {
+	t.Helper()
+	g := NewWithT(t)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	g.Expect(err).ToNot(HaveOccurred())
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "fulcio"},
+		NotBefore:             time.Now().Add(-24 * time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	g.Expect(err).ToNot(HaveOccurred())
+	cert, err := x509.ParseCertificate(der)
+	g.Expect(err).ToNot(HaveOccurred())
+	return &fulcio{cert: cert, key: key}
+}
+
+// sign signs the image with the given digest with a certificate for the given
+// identity, which expired since, and logs the signature in Rekor while the
+// certificate was valid. The bundle can be tampered with after it is signed.
+func (f *fulcio) sign(digest name.Digest, issuer, subject string, rekorKey *ecdsa.PrivateKey, tamper func(map[string]interface{})) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	issuerV2, err := asn1.MarshalWithParams(issuer, "utf8")
+	if err != nil {
+		return err
+	}
+	subjectURI, err := url.Parse(subject)
+	if err != nil {
+		return err
+	}
+	signedAt := time.Now().Add(-time.Hour)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		NotBefore:    signedAt.Add(-time.Minute),
+		NotAfter:     signedAt.Add(10 * time.Minute),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		URIs:         []*url.URL{subjectURI},
+		ExtraExtensions: []pkix.Extension{
+			// The OIDC issuer, as a raw string and as a UTF8String.
+			{Id: asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 1}, Value: []byte(issuer)},
+			{Id: asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 8}, Value: issuerV2},
+		},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, f.cert, &key.PublicKey, f.key)
+	if err != nil {
+		return err
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+
+	payload := test.CosignPayload(digest)
+	payloadHash := sha256.Sum256(payload)
+	sig, err := ecdsa.SignASN1(rand.Reader, key, payloadHash[:])
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"apiVersion": "0.0.1",
+		"kind":       "hashedrekord",
+		"spec": map[string]interface{}{
+			"data": map[string]interface{}{
+				"hash": map[string]string{"algorithm": "sha256", "value": hex.EncodeToString(payloadHash[:])},
+			},
+			"signature": map[string]interface{}{
+				"content":   sig,
+				"publicKey": map[string]interface{}{"content": certPEM},
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	logID, err := cosign.GetTransparencyLogID(&rekorKey.PublicKey)
+	if err != nil {
+		return err
+	}
+	entry := map[string]interface{}{
+		"body":           base64.StdEncoding.EncodeToString(body),
+		"integratedTime": signedAt.Unix(),
+		"logID":          logID,
+		"logIndex":       42,
+	}
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	entryHash := sha256.Sum256(b)
+	set, err := ecdsa.SignASN1(rand.Reader, rekorKey, entryHash[:])
+	if err != nil {
+		return err
+	}
+	bundle := map[string]interface{}{"SignedEntryTimestamp": set, "Payload": entry}
+	if tamper != nil {
+		tamper(bundle)
+	}
+	bundleJSON, err := json.Marshal(bundle)
+	if err != nil {
+		return err
+	}
+
+	return test.WriteCosignSignature(digest, payload, map[string]string{
+		static.SignatureAnnotationKey:   base64.StdEncoding.EncodeToString(sig),
+		static.CertificateAnnotationKey: string(certPEM),
+		static.ChainAnnotationKey:       string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: f.cert.Raw})),
+		static.BundleAnnotationKey:      string(bundleJSON),
+	})
+}
@@ -17,14 +17,17 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
-	"github.com/dgraph-io/badger/v3"
 	flag "github.com/spf13/pflag"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
@@ -50,7 +53,12 @@ import (
 	imagev1 "github.com/fluxcd/image-reflector-controller/api/v1beta2"
 	"github.com/fluxcd/image-reflector-controller/internal/controller"
 	"github.com/fluxcd/image-reflector-controller/internal/database"
+	"github.com/fluxcd/image-reflector-controller/internal/evaluation"
 	"github.com/fluxcd/image-reflector-controller/internal/features"
+	"github.com/fluxcd/image-reflector-controller/internal/ratelimit"
+	"github.com/fluxcd/image-reflector-controller/internal/receiver"
+	"github.com/fluxcd/image-reflector-controller/internal/snapshot"
+	"github.com/fluxcd/image-reflector-controller/internal/verify"
 )
 
 const controllerName = "image-reflector-controller"
@@ -72,12 +80,26 @@ func main() {
 		metricsAddr             string
 		eventsAddr              string
 		healthAddr              string
+		webhookAddr             string
+		webhookTokenFile        string
+		evaluationAddr          string
+		evaluationTokenFile     string
+		registryQPS             float64
+		registryBurst           int
+		registryRateLimitsFile  string
+		keylessFulcioRootsFile  string
+		keylessRekorKeysFile    string
 		clientOptions           client.Options
 		logOptions              logger.Options
 		leaderElectionOptions   leaderelection.Options
 		watchOptions            helper.WatchOptions
+		storageBackend          string
 		storagePath             string
+		storageURL              string
 		storageValueLogFileSize int64
+		storageSweepInterval    time.Duration
+		snapshotConfigMap       string
+		snapshotInterval        time.Duration
 		concurrent              int
 		awsAutoLogin            bool
 		gcpAutoLogin            bool
@@ -90,9 +112,23 @@ func main() {
 	flag.StringVar(&metricsAddr, "metrics-addr", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&eventsAddr, "events-addr", "", "The address of the events receiver.")
 	flag.StringVar(&healthAddr, "health-addr", ":9440", "The address the health endpoint binds to.")
+	flag.StringVar(&webhookAddr, "webhook-addr", "", "The address the registry push notification receiver binds to. The receiver is disabled when empty.")
+	flag.StringVar(&webhookTokenFile, "webhook-token-file", "", "The path to a file containing the token push notifications must be authenticated with. Required with --webhook-addr.")
+	flag.StringVar(&evaluationAddr, "policy-evaluation-addr", "", "The address the policy evaluation endpoint binds to. The endpoint is disabled when empty.")
+	flag.StringVar(&evaluationTokenFile, "policy-evaluation-token-file", "", "The path to a file containing the token policy evaluation requests must be authenticated with. Required with --policy-evaluation-addr.")
+	flag.StringVar(&storageBackend, "storage-backend", database.BadgerBackend, fmt.Sprintf("The backend storing the database of image metadata, one of: %s.", strings.Join(database.Backends(), ", ")))
 	flag.StringVar(&storagePath, "storage-path", "/data", "Where to store the persistent database of image metadata")
+	flag.StringVar(&storageURL, "storage-url", "", "The URL of the server of the networked storage backends, e.g. redis://redis:6379/0 for the redis backend.")
 	flag.Int64Var(&storageValueLogFileSize, "storage-value-log-file-size", 1<<28, "Set the database's memory mapped value log file size in bytes. Effective memory usage is about two times this size.")
+	flag.DurationVar(&storageSweepInterval, "storage-sweep-interval", time.Hour, "The interval at which the data of the images no longer scanned by any ImageRepository is removed from the database. Zero disables the removal.")
+	flag.StringVar(&snapshotConfigMap, "storage-snapshot-configmap", "", "The name of the ConfigMap, in the namespace of the controller, the database is periodically saved to and restored from when empty at startup. The snapshots are disabled when empty.")
+	flag.DurationVar(&snapshotInterval, "storage-snapshot-interval", 10*time.Minute, "The interval at which the database is saved to the snapshot ConfigMap.")
 	flag.IntVar(&concurrent, "concurrent", 4, "The number of concurrent resource reconciles.")
+	flag.Float64Var(&registryQPS, "registry-qps", 0, "The maximum number of requests per second made to each registry host. Zero means unlimited.")
+	flag.IntVar(&registryBurst, "registry-burst", 10, "The maximum number of requests made at once to each registry host.")
+	flag.StringVar(&registryRateLimitsFile, "registry-rate-limits-file", "", "The path to a YAML file, e.g. a mounted ConfigMap, with the rate limits of specific registry hosts.")
+	flag.StringVar(&keylessFulcioRootsFile, "keyless-fulcio-roots-file", "", "The path to a PEM file with the Fulcio root certificates trusted for keyless signature verification.")
+	flag.StringVar(&keylessRekorKeysFile, "keyless-rekor-keys-file", "", "The path to a PEM file with the Rekor public keys trusted for keyless signature verification.")
 
 	// NOTE: Deprecated flags.
 	flag.BoolVar(&awsAutoLogin, "aws-autologin-for-ecr", false, "(AWS) Attempt to get credentials for images in Elastic Container Registry, when no secret is referenced")
@@ -122,15 +158,16 @@ func main() {
 		os.Exit(1)
 	}
 
-	badgerOpts := badger.DefaultOptions(storagePath)
-	badgerOpts.ValueLogFileSize = storageValueLogFileSize
-	badgerDB, err := badger.Open(badgerOpts)
+	db, closeDB, err := database.Open(storageBackend, database.Options{
+		Path:             storagePath,
+		ValueLogFileSize: storageValueLogFileSize,
+		URL:              storageURL,
+	})
 	if err != nil {
-		setupLog.Error(err, "unable to open the Badger database")
+		setupLog.Error(err, "unable to open the database", "backend", storageBackend)
 		os.Exit(1)
 	}
-	defer badgerDB.Close()
-	db := database.NewBadgerDatabase(badgerDB)
+	defer closeDB()
 
 	watchNamespace := ""
 	if !watchOptions.AllNamespaces {
@@ -204,31 +241,54 @@ func main() {
 
 	metricsH := helper.MustMakeMetrics(mgr)
 
-	if err := (&controller.ImageRepositoryReconciler{
-		Client:         mgr.GetClient(),
-		EventRecorder:  eventRecorder,
-		Metrics:        metricsH,
-		Database:       db,
-		ControllerName: controllerName,
+	rateLimits := ratelimit.Config{
+		Default: ratelimit.Limit{QPS: registryQPS, Burst: registryBurst},
+	}
+	if registryRateLimitsFile != "" {
+		if rateLimits, err = ratelimit.LoadConfig(registryRateLimitsFile, rateLimits.Default); err != nil {
+			setupLog.Error(err, "unable to load the registry rate limits")
+			os.Exit(1)
+		}
+	}
+
+	imageRepositoryReconciler := &controller.ImageRepositoryReconciler{
+		Client:          mgr.GetClient(),
+		EventRecorder:   eventRecorder,
+		Metrics:         metricsH,
+		Database:        db,
+		ControllerName:  controllerName,
+		RegistryLimiter: ratelimit.New(rateLimits),
 		DeprecatedLoginOpts: login.ProviderOptions{
 			AwsAutoLogin:   awsAutoLogin,
 			AzureAutoLogin: azureAutoLogin,
 			GcpAutoLogin:   gcpAutoLogin,
 		},
-	}).SetupWithManager(mgr, controller.ImageRepositoryReconcilerOptions{
+	}
+	if err := imageRepositoryReconciler.SetupWithManager(mgr, controller.ImageRepositoryReconcilerOptions{
 		RateLimiter: helper.GetRateLimiter(rateLimiterOptions),
 	}); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", imagev1.ImageRepositoryKind)
 		os.Exit(1)
 	}
-	if err := (&controller.ImagePolicyReconciler{
-		Client:         mgr.GetClient(),
-		EventRecorder:  eventRecorder,
-		Metrics:        metricsH,
-		Database:       db,
-		ACLOptions:     aclOptions,
-		ControllerName: controllerName,
-	}).SetupWithManager(mgr, controller.ImagePolicyReconcilerOptions{
+	var keylessRoot *verify.TrustedRoot
+	if keylessFulcioRootsFile != "" || keylessRekorKeysFile != "" {
+		keylessRoot, err = verify.LoadTrustedRoot(keylessFulcioRootsFile, keylessRekorKeysFile)
+		if err != nil {
+			setupLog.Error(err, "unable to load the roots trusted for keyless verification")
+			os.Exit(1)
+		}
+	}
+	imagePolicyReconciler := &controller.ImagePolicyReconciler{
+		Client:             mgr.GetClient(),
+		EventRecorder:      eventRecorder,
+		Metrics:            metricsH,
+		Database:           db,
+		ACLOptions:         aclOptions,
+		ControllerName:     controllerName,
+		RegistryOptions:    imageRepositoryReconciler,
+		KeylessTrustedRoot: keylessRoot,
+	}
+	if err := imagePolicyReconciler.SetupWithManager(mgr, controller.ImagePolicyReconcilerOptions{
 		RateLimiter: helper.GetRateLimiter(rateLimiterOptions),
 	}); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", imagev1.ImagePolicyKind)
@@ -236,9 +296,112 @@ func main() {
 	}
 	// +kubebuilder:scaffold:builder
 
+	if storageSweepInterval > 0 {
+		if err := mgr.Add(&controller.DatabaseSweeper{
+			Client:   mgr.GetClient(),
+			Database: db,
+			Interval: storageSweepInterval,
+			Logger:   ctrl.Log.WithName("database-sweeper"),
+		}); err != nil {
+			setupLog.Error(err, "unable to create database sweeper")
+			os.Exit(1)
+		}
+	}
+
+	if evaluationAddr != "" {
+		token, err := readToken("--policy-evaluation-token-file", evaluationTokenFile)
+		if err != nil {
+			setupLog.Error(err, "unable to create policy evaluation server")
+			os.Exit(1)
+		}
+		if err := mgr.Add(&evaluation.Server{
+			Addr:      evaluationAddr,
+			Token:     token,
+			Database:  db,
+			Evaluator: imagePolicyReconciler,
+			Logger:    ctrl.Log.WithName("policy-evaluation"),
+		}); err != nil {
+			setupLog.Error(err, "unable to create policy evaluation server")
+			os.Exit(1)
+		}
+	}
+
+	if webhookAddr != "" {
+		token, err := readToken("--webhook-token-file", webhookTokenFile)
+		if err != nil {
+			setupLog.Error(err, "unable to create push notification receiver")
+			os.Exit(1)
+		}
+		if err := mgr.Add(&receiver.Receiver{
+			Addr:    webhookAddr,
+			Token:   token,
+			Scanner: imageRepositoryReconciler,
+			Logger:  ctrl.Log.WithName("receiver"),
+		}); err != nil {
+			setupLog.Error(err, "unable to create push notification receiver")
+			os.Exit(1)
+		}
+	}
+
+	ctx := ctrl.SetupSignalHandler()
+
+	if snapshotConfigMap != "" {
+		// The snapshot is saved in the namespace of the controller, where the
+		// Role of the leader election gives access to the ConfigMaps.
+		snapshotNamespace := os.Getenv("RUNTIME_NAMESPACE")
+		if snapshotNamespace == "" {
+			setupLog.Error(errors.New("RUNTIME_NAMESPACE is not set"), "unable to set up the database snapshots")
+			os.Exit(1)
+		}
+		snapshotter := &snapshot.Snapshotter{
+			Client:    mgr.GetClient(),
+			APIReader: mgr.GetAPIReader(),
+			Database:  db,
+			ConfigMap: types.NamespacedName{
+				Namespace: snapshotNamespace,
+				Name:      snapshotConfigMap,
+			},
+			Interval: snapshotInterval,
+			Logger:   ctrl.Log.WithName("database-snapshotter"),
+		}
+		// Restore the database before the reconcilers start, for them not to
+		// scan all the images again.
+		restoreCtx, cancel := context.WithTimeout(ctx, time.Minute)
+		restored, err := snapshotter.Restore(restoreCtx)
+		cancel()
+		if err != nil {
+			// The images are scanned again instead, the controller doesn't
+			// depend on the snapshot to start.
+			setupLog.Error(err, "unable to restore the database snapshot")
+		} else if restored {
+			setupLog.Info("restored the database snapshot", "configmap", snapshotter.ConfigMap)
+		}
+		if err := mgr.Add(snapshotter); err != nil {
+			setupLog.Error(err, "unable to create database snapshotter")
+			os.Exit(1)
+		}
+	}
+
 	setupLog.Info("starting manager")
-	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
+	if err := mgr.Start(ctx); err != nil {
 		setupLog.Error(err, "problem running manager")
 		os.Exit(1)
 	}
 }
+
+// readToken reads the token in the file at the given path, given with the
+// named flag. It returns an error if the path or the token is empty.
+func readToken(flagName, path string) (string, error) {
+	if path == "" {
+		return "", fmt.Errorf("%s is required", flagName)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read token: %w", err)
+	}
+	token := strings.TrimSpace(string(b))
+	if token == "" {
+		return "", fmt.Errorf("the token in %s is empty", path)
+	}
+	return token, nil
+}